@@ -0,0 +1,182 @@
+package app
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"microservice/app/core"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+var (
+	apiKeysMx sync.RWMutex
+	apiKeys   []*apiKey
+
+	apiKeysDecodeHook = viper.DecodeHook(mapstructure.StringToTimeHookFunc(time.RFC3339))
+)
+
+// ApiKey as it is described in config or keys file
+type ApiKey struct {
+	Name     string    `mapstructure:"name"`
+	Hash     string    `mapstructure:"hash"`      // hex sha256 of the key
+	Scope    string    `mapstructure:"scope"`     // read-only | admin
+	NotAfter time.Time `mapstructure:"not_after"` // RFC3339, empty means no expiry
+}
+
+type apiKey struct {
+	name     string
+	hash     []byte
+	role     core.AccessRole
+	notAfter time.Time
+}
+
+func initApiKeys() error {
+
+	err := reloadApiKeys()
+	if err != nil {
+		return err
+	}
+
+	files := []string{viper.ConfigFileUsed()}
+	if file := viper.GetString("app.api_keys.file"); file != "" {
+		files = append(files, file)
+	}
+
+	interval := viper.GetDuration("app.api_keys.reload_interval")
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	watchFiles(interval, func() {
+		err := reloadApiKeys()
+		if err != nil {
+			log.ErrorWrap(err, "cannot reload api keys, previous keys are kept")
+		}
+	}, files...)
+
+	return nil
+}
+
+func reloadApiKeys() error {
+
+	// Read config again because global viper doesn't see changes of file
+	cfg := viper.New()
+	cfg.SetConfigFile(viper.ConfigFileUsed())
+	if err := cfg.ReadInConfig(); err != nil {
+		return errors.Wrap(err, "cannot read config with api keys")
+	}
+
+	var list []ApiKey
+	if err := cfg.UnmarshalKey("app.api_keys.keys", &list, apiKeysDecodeHook); err != nil {
+		return errors.Wrap(err, "cannot parse app.api_keys.keys")
+	}
+
+	if file := viper.GetString("app.api_keys.file"); file != "" {
+		keysFile := viper.New()
+		keysFile.SetConfigFile(file)
+		if err := keysFile.ReadInConfig(); err != nil {
+			return errors.Wrapf(err, "cannot read api keys file %s", file)
+		}
+		var fromFile []ApiKey
+		if err := keysFile.UnmarshalKey("keys", &fromFile, apiKeysDecodeHook); err != nil {
+			return errors.Wrapf(err, "cannot parse api keys file %s", file)
+		}
+		list = append(list, fromFile...)
+	}
+
+	keys := make([]*apiKey, 0, len(list)+1)
+	for _, k := range list {
+		key, err := k.parse()
+		if err != nil {
+			return errors.Wrapf(err, "incorrect api key %s", k.Name)
+		}
+		keys = append(keys, key)
+	}
+
+	// Legacy single secret works as admin key
+	if secret := viper.GetString("app.secret"); secret != "" {
+		sum := sha256.Sum256([]byte(secret))
+		keys = append(keys, &apiKey{
+			name: "app.secret",
+			hash: sum[:],
+			role: core.RoleSuperAdmin,
+		})
+	}
+
+	apiKeysMx.Lock()
+	apiKeys = keys
+	apiKeysMx.Unlock()
+
+	log.Info("Api keys were loaded: %d", len(keys))
+	return nil
+}
+
+func (k *ApiKey) parse() (*apiKey, error) {
+	if k.Name == "" {
+		return nil, errors.New("name is required")
+	}
+
+	hash, err := hex.DecodeString(k.Hash)
+	if err != nil || len(hash) != sha256.Size {
+		return nil, errors.New("hash should be hex encoded sha256")
+	}
+
+	var role core.AccessRole
+	switch strings.ToLower(k.Scope) {
+	case "read-only", "readonly", "read":
+		role = core.RoleUser
+	case "admin":
+		role = core.RoleSuperAdmin
+	default:
+		return nil, errors.Errorf("unknown scope %s", k.Scope)
+	}
+
+	return &apiKey{
+		name:     k.Name,
+		hash:     hash,
+		role:     role,
+		notAfter: k.NotAfter,
+	}, nil
+}
+
+// matchApiKey finds key by its raw value. Every key is compared to not leak position by timing.
+func matchApiKey(token string) (*apiKey, error) {
+	sum := sha256.Sum256([]byte(token))
+
+	apiKeysMx.RLock()
+	defer apiKeysMx.RUnlock()
+
+	var found *apiKey
+	for _, k := range apiKeys {
+		if subtle.ConstantTimeCompare(sum[:], k.hash) == 1 {
+			found = k
+		}
+	}
+
+	if found == nil {
+		return nil, errors.New("unknown api key")
+	}
+	if !found.notAfter.IsZero() && time.Now().After(found.notAfter) {
+		return nil, errors.Errorf("api key %s is expired", found.name)
+	}
+	return found, nil
+}
+
+// ApiKeyIdentity returns identity of raw api key, e.g. for REST handlers which don't pass gRPC interceptors
+func ApiKeyIdentity(token string) (*core.Identity, error) {
+	key, err := matchApiKey(token)
+	if err != nil {
+		return nil, err
+	}
+	return &core.Identity{
+		UserId:    -1,
+		Role:      key.role,
+		Principal: "key:" + key.name,
+	}, nil
+}
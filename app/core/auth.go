@@ -1,5 +1,13 @@
 package core
 
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
 type AccessRole int
 
 const (
@@ -7,3 +15,83 @@ const (
 	RoleUser       AccessRole = 1
 	RoleSuperAdmin AccessRole = 10
 )
+
+// ParseAccessRole accepts role name (guest, user, super_admin) or its numeric value
+func ParseAccessRole(s string) (AccessRole, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "guest":
+		return RoleGuest, nil
+	case "user":
+		return RoleUser, nil
+	case "admin", "super_admin", "superadmin":
+		return RoleSuperAdmin, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return RoleGuest, errors.Errorf("unknown access role %s", s)
+	}
+	return AccessRole(n), nil
+}
+
+// Identity of the caller which was verified by auth interceptors
+type Identity struct {
+	UserId    int64 // -1 if caller didn't pass user
+	Role      AccessRole
+	Principal string // who made the call, e.g. "key:admin-tool" or "user:42"
+}
+
+type identityKey struct{}
+type identityHolderKey struct{}
+
+// identityHolder gets identity of the call when it is set later by inner interceptor
+type identityHolder struct {
+	identity *Identity
+}
+
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	if holder, ok := ctx.Value(identityHolderKey{}).(*identityHolder); ok {
+		holder.identity = identity
+	}
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+func IdentityFromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+	return identity, ok && identity != nil
+}
+
+// WithIdentityHolder is set by the outer interceptor, so its logs know caller authenticated by inner ones
+func WithIdentityHolder(ctx context.Context) context.Context {
+	return context.WithValue(ctx, identityHolderKey{}, &identityHolder{})
+}
+
+// LoggedIdentity returns identity of context or identity which was set later in the same call.
+// It is only for logs, access is checked by IdentityFromContext.
+func LoggedIdentity(ctx context.Context) (*Identity, bool) {
+	if identity, ok := IdentityFromContext(ctx); ok {
+		return identity, true
+	}
+	holder, ok := ctx.Value(identityHolderKey{}).(*identityHolder)
+	if !ok || holder.identity == nil {
+		return nil, false
+	}
+	return holder.identity, true
+}
+
+// PeerCertificate is verified client certificate of mTLS connection
+type PeerCertificate struct {
+	Subject    string
+	CommonName string
+	URIs       []string
+}
+
+type peerCertificateKey struct{}
+
+func WithPeerCertificate(ctx context.Context, cert *PeerCertificate) context.Context {
+	return context.WithValue(ctx, peerCertificateKey{}, cert)
+}
+
+func PeerCertificateFromContext(ctx context.Context) (*PeerCertificate, bool) {
+	cert, ok := ctx.Value(peerCertificateKey{}).(*PeerCertificate)
+	return cert, ok && cert != nil
+}
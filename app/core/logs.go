@@ -1,5 +1,7 @@
 package core
 
+import "context"
+
 type Logger interface {
 	Debug(msg string, args ...interface{})
 	Warn(msg string, args ...interface{})
@@ -12,4 +14,21 @@ type Logger interface {
 	InfoWrap(err error, msg string, args ...interface{})
 	ErrorWrap(err error, msg string, args ...interface{})
 	FatalWrap(err error, msg string, args ...interface{})
+
+	// With returns logger which adds fields to every message, fields are key-value pairs: With("topic", t, "offset", o)
+	With(fields ...interface{}) Logger
+	// FromContext returns logger with request id, method, user_id and trace id of ctx
+	FromContext(ctx context.Context) Logger
+}
+
+type methodKey struct{}
+
+// WithMethod keeps name of handler which serves ctx, e.g. gRPC method, for logs
+func WithMethod(ctx context.Context, method string) context.Context {
+	return context.WithValue(ctx, methodKey{}, method)
+}
+
+func MethodFromContext(ctx context.Context) (string, bool) {
+	method, ok := ctx.Value(methodKey{}).(string)
+	return method, ok && method != ""
 }
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Trace links gRPC calls and kafka messages caused by one request
+type Trace struct {
+	RequestId   string
+	TraceParent string // W3C traceparent, e.g. 00-<trace id>-<parent id>-01
+	TraceState  string // W3C tracestate, passed as is
+}
+
+type traceKey struct{}
+
+func WithTrace(ctx context.Context, trace *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+func TraceFromContext(ctx context.Context) (*Trace, bool) {
+	trace, ok := ctx.Value(traceKey{}).(*Trace)
+	return trace, ok && trace != nil
+}
+
+// String is used in logs
+func (t *Trace) String() string {
+	var parts []string
+	if t.RequestId != "" {
+		parts = append(parts, "request_id="+t.RequestId)
+	}
+	if t.TraceParent != "" {
+		parts = append(parts, "traceparent="+t.TraceParent)
+	}
+	return strings.Join(parts, " ")
+}
+
+func NewRequestId() string {
+	return uuid.NewString()
+}
+
+// NewTraceParent starts new sampled trace
+func NewTraceParent() string {
+	traceId := make([]byte, 16)
+	parentId := make([]byte, 8)
+	_, _ = rand.Read(traceId)
+	_, _ = rand.Read(parentId)
+	return "00-" + hex.EncodeToString(traceId) + "-" + hex.EncodeToString(parentId) + "-01"
+}
+
+// ValidTraceParent checks format of version 00 of traceparent
+func ValidTraceParent(s string) bool {
+	parts := strings.Split(s, "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return false
+	}
+	for _, part := range parts {
+		if _, err := hex.DecodeString(part); err != nil {
+			return false
+		}
+	}
+	// All zero ids are invalid
+	return strings.Trim(parts[1], "0") != "" && strings.Trim(parts[2], "0") != "" && parts[0] != "ff"
+}
@@ -1,5 +1,7 @@
 package core
 
+import "reflect"
+
 const (
 	Success         = "success"
 	ServerError     = "server_error"
@@ -21,3 +23,31 @@ type IdResponse struct {
 	Status Status `json:"status"`
 	Id     int32
 }
+
+type DataResponse[T any] struct {
+	Status Status `json:"status"`
+	Data   T      `json:"data"`
+}
+
+// ResponseStatusCode returns resp.GetStatus().GetCode() of gRPC response, resp.GetCode() if response is Status itself
+// (e.g. DeleteNewsCard) or empty string, responses are different types without common interface
+func ResponseStatusCode(resp interface{}) string {
+	if resp == nil {
+		return ""
+	}
+	if v := reflect.ValueOf(resp); v.Kind() == reflect.Pointer && v.IsNil() {
+		return ""
+	}
+	if s, ok := resp.(interface{ GetCode() string }); ok {
+		return s.GetCode()
+	}
+	method := reflect.ValueOf(resp).MethodByName("GetStatus")
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+		return ""
+	}
+	s, ok := method.Call(nil)[0].Interface().(interface{ GetCode() string })
+	if !ok || reflect.ValueOf(s).IsNil() {
+		return ""
+	}
+	return s.GetCode()
+}
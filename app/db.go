@@ -72,7 +72,8 @@ func RunMigrations(rootDir ...string) error {
 		basePath = rootDir[0]
 	}
 
-	err := goose.Up(db, basePath+"/migrations")
+	migrationsDir = basePath + "/migrations"
+	err := goose.Up(db, migrationsDir)
 	if err != nil {
 		return err
 	}
@@ -2,8 +2,6 @@ package app
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
@@ -11,18 +9,22 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
-	"io/ioutil"
 	"microservice/app/core"
+	"microservice/app/metrics"
+	"microservice/app/tracing"
 	"net"
 	"strconv"
+	"strings"
 )
 
 var (
-	grpcServer *grpc.Server
-	grpcMux    *runtime.ServeMux
+	grpcServer  *grpc.Server
+	grpcMux     *runtime.ServeMux
+	methodRoles = make(map[string]core.AccessRole)
 )
 
 // GRPC
@@ -30,51 +32,47 @@ func InitGRPCServer() (*grpc.Server, *runtime.ServeMux, error) {
 
 	var options []grpc.ServerOption
 
-	// TSL
-	tslEnable := viper.GetBool("grpc.tsl_enabled")
-	if tslEnable {
-		crt := "./cert/service.pem"
-		key := "./cert/service.key"
-		caN := "./cert/ca.cert"
-
-		// Load the certificates from disk
-		certificate, err := tls.LoadX509KeyPair(crt, key)
-		if err != nil {
-			return nil, nil, errors.New("cannot initialize GRPC Server")
-		}
-
-		// UserCreate a certificate pool from the certificate authority
-		certPool := x509.NewCertPool()
-		ca, err := ioutil.ReadFile(caN)
+	// TLS
+	tlsEnable := tlsEnabled()
+	if tlsEnable {
+		tlsConfig, err := initServerTLS()
 		if err != nil {
-			return nil, nil, errors.New("cannot initialize GRPC Server")
+			return nil, nil, errors.Wrap(err, "cannot init TLS")
 		}
-
-		// Append the client certificates from the CA
-		if ok := certPool.AppendCertsFromPEM(ca); !ok {
-			return nil, nil, errors.New("failed to append client certs")
-		}
-
-		// UserCreate the TLS credentials
-		creds := credentials.NewTLS(&tls.Config{
-			ClientAuth:   tls.RequireAndVerifyClientCert,
-			Certificates: []tls.Certificate{certificate},
-			ClientCAs:    certPool,
-		})
-		options = append(options, grpc.Creds(creds))
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
 	}
 
 	// Middleware
 	mv := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(traceContext),
+		grpc.ChainUnaryInterceptor(tracing.UnaryServerInterceptor),
+		grpc.ChainUnaryInterceptor(metrics.UnaryServerInterceptor),
 		grpc.ChainUnaryInterceptor(errorLogging),
-		grpc.ChainUnaryInterceptor(anyLogging),
+	}
+
+	if tlsEnable {
+		if err := initCertIdentities(); err != nil {
+			return nil, nil, errors.Wrap(err, "cannot init certificate identities")
+		}
+		mv = append(mv, grpc.ChainUnaryInterceptor(peerIdentity))
+	}
+
+	// Auth
+	if err := initJWT(); err != nil {
+		return nil, nil, errors.Wrap(err, "cannot init jwt auth")
+	}
+	if err := initApiKeys(); err != nil {
+		return nil, nil, errors.Wrap(err, "cannot init api keys")
 	}
 
 	debug := viper.GetBool("app.debug")
-	if !debug {
-		mv = append(mv, grpc.ChainUnaryInterceptor(fromGWOnly))
+	if jwtVerifier != nil || !debug {
+		mv = append(mv, grpc.ChainUnaryInterceptor(authenticate, authorize))
 	}
 
+	// After auth, so log knows user, denied calls are logged by errorLogging
+	mv = append(mv, grpc.ChainUnaryInterceptor(anyLogging))
+
 	options = append(options, mv...)
 
 	// UserCreate server
@@ -83,7 +81,11 @@ func InitGRPCServer() (*grpc.Server, *runtime.ServeMux, error) {
 		return nil, nil, errors.New("cannot initialize GRPC Server")
 	}
 
-	grpcMux = runtime.NewServeMux()
+	grpcMux = newGatewayMux()
+
+	// Health, serving after the first successful readiness check
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
 
 	return grpcServer, grpcMux, nil
 }
@@ -134,33 +136,81 @@ func InitGRPCService[T any](s func(grpc.ServiceRegistrar, T), src T) {
 	s(grpcServer, src)
 }
 
-// Logging interceptor
+// RequireRole sets minimal role for gRPC method, e.g. /pb.NewsService/AddNewsCard
+func RequireRole(method string, role core.AccessRole) {
+	methodRoles[method] = role
+}
+
+// Auth interceptors
+
+func authenticate(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+
+	// Already known by client certificate
+	if _, ok := core.IdentityFromContext(ctx); ok {
+		return handler(ctx, req)
+	}
+
+	m, _ := metadata.FromIncomingContext(ctx)
+	tokens := m.Get("Authorization")
+	if jwtVerifier != nil && len(tokens) > 0 && strings.HasPrefix(tokens[0], "Bearer ") {
+		return jwtAuth(ctx, req, info, handler)
+	}
+	return fromGWOnly(ctx, req, info, handler)
+}
 
 func fromGWOnly(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
 
-	isAuth := false
-	m, ok := metadata.FromIncomingContext(ctx)
-	if ok {
-		tokens := m.Get("Authorization")
-		if len(tokens) > 0 && viper.GetString("app.secret") == tokens[0] {
-			isAuth = true
-		}
+	m, _ := metadata.FromIncomingContext(ctx)
+	tokens := m.Get("Authorization")
+	if len(tokens) == 0 {
+		return nil, status.Errorf(codes.Unauthenticated, "DENIED access without AUTH! %s", info.FullMethod)
 	}
 
-	if isAuth {
-		return handler(ctx, req)
-	} else {
-		return nil, errors.Errorf("DENIED access without AUTH! %s", info.FullMethod)
+	key, err := matchApiKey(tokens[0])
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "DENIED access without AUTH! %s: %s", info.FullMethod, err.Error())
 	}
+
+	userId, err := metadataUserId(m)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "cannot parse user_id: %s", err.Error())
+	}
+
+	ctx = core.WithIdentity(ctx, &core.Identity{
+		UserId:    userId,
+		Role:      key.role,
+		Principal: "key:" + key.name,
+	})
+	return handler(ctx, req)
 }
 
+func authorize(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	identity, ok := core.IdentityFromContext(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "DENIED access without AUTH! %s", info.FullMethod)
+	}
+
+	if role, ok := methodRoles[info.FullMethod]; ok && identity.Role < role {
+		log.FromContext(ctx).Warn("AUDIT: %s denied for %s", info.FullMethod, identity.Principal)
+		return nil, status.Errorf(codes.PermissionDenied, "DENIED access for %s! %s", identity.Principal, info.FullMethod)
+	}
+
+	log.FromContext(ctx).Info("AUDIT: %s called by %s", info.FullMethod, identity.Principal)
+	return handler(ctx, req)
+}
+
+// Logging interceptor
+
 func errorLogging(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
 	// Calls the handler
 	h, err := handler(ctx, req)
 
 	// Log if error
 	if err != nil {
-		log.Error("%v", err)
+		log.FromContext(ctx).Error("%v", err)
+		if _, ok := status.FromError(err); ok {
+			return h, err
+		}
 		return h, status.Error(codes.Internal, err.Error())
 	}
 
@@ -168,13 +218,36 @@ func errorLogging(ctx context.Context, req interface{}, info *grpc.UnaryServerIn
 }
 
 func anyLogging(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-	log.Info("New call %s", info.FullMethod)
+	log.FromContext(ctx).Info("New call %s", info.FullMethod)
 	return handler(ctx, req)
 }
 
 // Tools
 
+// metadataUserId returns user passed by gateway in metadata or -1
+func metadataUserId(m metadata.MD) (int64, error) {
+	userIds := m.Get("user_id")
+	if len(userIds) == 0 {
+		return -1, nil
+	}
+	return strconv.ParseInt(userIds[0], 10, 64)
+}
+
 func ExtractRequestUserId(ctx context.Context) (int64, error) {
+
+	// Verified by auth interceptor
+	if identity, ok := core.IdentityFromContext(ctx); ok {
+		if identity.UserId < 0 {
+			return -1, errors.New("user_id was not found into context")
+		}
+		return identity.UserId, nil
+	}
+
+	// Plain metadata is trusted only without JWT auth
+	if jwtVerifier != nil {
+		return -1, errors.New("user_id was not found into context")
+	}
+
 	m, ok := metadata.FromIncomingContext(ctx)
 	if ok {
 		userIds := m.Get("user_id")
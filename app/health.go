@@ -0,0 +1,276 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pressly/goose"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// ReadinessCheck returns error if service cannot serve requests now
+type ReadinessCheck func(ctx context.Context) error
+
+var (
+	healthServer = health.NewServer()
+	shuttingDown atomic.Bool
+
+	readyMx      sync.RWMutex
+	readyChecks  = make(map[string]ReadinessCheck)
+	readyResults map[string]string // check -> error, empty if passed
+	ready        bool
+
+	migrationsDir string
+
+	tlsWarnedExpiry time.Time // expiry of certificate which was warned about, not to warn on every check
+)
+
+// AddReadinessCheck adds check to /readyz and gRPC health status, e.g. connection to broker
+func AddReadinessCheck(name string, check ReadinessCheck) {
+	readyMx.Lock()
+	defer readyMx.Unlock()
+	readyChecks[name] = check
+}
+
+// RunHealthChecks serves /healthz and /readyz and updates gRPC health status every app.health.interval
+func RunHealthChecks(ctx context.Context) {
+
+	if db != nil {
+		AddReadinessCheck("database", func(ctx context.Context) error {
+			return db.PingContext(ctx)
+		})
+		AddReadinessCheck("migrations", checkMigrations)
+	}
+	AddReadinessCheck("storage", checkStorage)
+	if tlsEnabled() {
+		AddReadinessCheck("tls", checkTLSExpiry)
+	}
+
+	HandleHTTP("/healthz", http.HandlerFunc(liveness))
+	HandleHTTP("/readyz", http.HandlerFunc(readiness))
+
+	interval := viper.GetDuration("app.health.interval")
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			updateReadiness(ctx)
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func updateReadiness(ctx context.Context) {
+
+	timeout := viper.GetDuration("app.health.timeout")
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	readyMx.RLock()
+	checks := make(map[string]ReadinessCheck, len(readyChecks))
+	for name, check := range readyChecks {
+		checks[name] = check
+	}
+	readyMx.RUnlock()
+
+	// Checks are independent, slow broker shouldn't delay database check
+	var (
+		wg      sync.WaitGroup
+		mx      sync.Mutex
+		results = make(map[string]string, len(checks))
+		ok      = true
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check ReadinessCheck) {
+			defer wg.Done()
+			err := check(ctx)
+			mx.Lock()
+			defer mx.Unlock()
+			results[name] = ""
+			if err != nil {
+				results[name] = err.Error()
+				ok = false
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	readyMx.Lock()
+	if ok != ready {
+		if ok {
+			log.Info("HEALTH: service is ready")
+		} else {
+			log.Warn("HEALTH: service is not ready: %v", results)
+		}
+	}
+	ready, readyResults = ok, results
+	readyMx.Unlock()
+
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if ok {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+	// Overall status and status of every registered service
+	healthServer.SetServingStatus("", status)
+	if grpcServer != nil {
+		for name := range grpcServer.GetServiceInfo() {
+			healthServer.SetServingStatus(name, status)
+		}
+	}
+}
+
+func checkMigrations(ctx context.Context) error {
+	current, err := goose.GetDBVersion(db)
+	if err != nil {
+		return errors.Wrap(err, "cannot get migrations version")
+	}
+	migrations, err := goose.CollectMigrations(migrationsDir, 0, goose.MaxVersion)
+	if err != nil {
+		return errors.Wrap(err, "cannot collect migrations")
+	}
+	last, err := migrations.Last()
+	if err != nil {
+		// No migrations at all
+		return nil
+	}
+	if current < last.Version {
+		return errors.Errorf("database version %d is behind migrations %d", current, last.Version)
+	}
+	return nil
+}
+
+func checkStorage(ctx context.Context) error {
+	f, err := os.CreateTemp(viper.GetString("storage.path"), ".readyz-*")
+	if err != nil {
+		return errors.Wrap(err, "storage is not writable")
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write([]byte("ok"))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return errors.Wrap(err, "storage is not writable")
+}
+
+// checkTLSExpiry fails after expiry and warns once app.grpc.tls.expiry_warning before it
+func checkTLSExpiry(ctx context.Context) error {
+	expiry, ok := TLSCertificateExpiry()
+	if !ok {
+		return nil
+	}
+	if time.Now().After(expiry) {
+		return errors.Errorf("server certificate expired at %s", expiry.Format(time.RFC3339))
+	}
+	if time.Until(expiry) < viper.GetDuration("app.grpc.tls.expiry_warning") && !expiry.Equal(tlsWarnedExpiry) {
+		tlsWarnedExpiry = expiry
+		log.Warn("TLS: server certificate expires at %s", expiry.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// liveness means only that process is alive, restart won't fix broken dependencies
+func liveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"status":"ok"}`))
+}
+
+func readiness(w http.ResponseWriter, r *http.Request) {
+
+	readyMx.RLock()
+	ok := ready && !shuttingDown.Load()
+	response := struct {
+		Status       string            `json:"status"`
+		Checks       map[string]string `json:"checks"`
+		TLSExpiresAt string            `json:"tls_expires_at,omitempty"`
+	}{
+		Status: "ready",
+		Checks: make(map[string]string, len(readyResults)),
+	}
+	if expiry, ok := TLSCertificateExpiry(); ok {
+		response.TLSExpiresAt = expiry.Format(time.RFC3339)
+	}
+	for name, result := range readyResults {
+		response.Checks[name] = "ok"
+		if result != "" {
+			response.Checks[name] = result
+		}
+	}
+	readyMx.RUnlock()
+
+	switch {
+	case shuttingDown.Load():
+		response.Status = "shutting_down"
+	case !ok:
+		response.Status = "not_ready"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// ShutdownTimeout is app.health.shutdown_timeout, time to finish active calls on shutdown
+func ShutdownTimeout() time.Duration {
+	timeout := viper.GetDuration("app.health.shutdown_timeout")
+	if timeout <= 0 {
+		timeout = 20 * time.Second
+	}
+	return timeout
+}
+
+// Shutdown reports NOT_SERVING, waits app.health.shutdown_delay to let balancers see it,
+// then stops HTTP and gRPC servers gracefully within app.health.shutdown_timeout
+func Shutdown() {
+
+	shuttingDown.Store(true)
+	healthServer.Shutdown()
+	log.Info("HEALTH: service is shutting down")
+
+	time.Sleep(viper.GetDuration("app.health.shutdown_delay"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout())
+	defer cancel()
+
+	if server := runningHTTPServer(); server != nil {
+		if err := server.Shutdown(ctx); err != nil {
+			log.ErrorWrap(err, "cannot stop HTTP server gracefully")
+		}
+	}
+
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			log.Warn("gRPC server is stopped with active calls")
+			grpcServer.Stop()
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var (
+	httpMux    = http.NewServeMux()
+	httpServer *http.Server
+	httpMx     sync.Mutex
+)
+
+// Headers which are passed between HTTP and gRPC as is, others get grpcgateway- prefix
+var gatewayHeaders = map[string]bool{
+	"authorization": true,
+	"user_id":       true,
+	requestIdKey:    true,
+	traceParentKey:  true,
+	traceStateKey:   true,
+}
+
+func newGatewayMux() *runtime.ServeMux {
+	return runtime.NewServeMux(
+		// Field names as in proto and OpenAPI spec, e.g. swipe_delay
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions: protojson.MarshalOptions{
+				UseProtoNames:   true,
+				EmitUnpopulated: true,
+			},
+			UnmarshalOptions: protojson.UnmarshalOptions{
+				DiscardUnknown: true,
+			},
+		}),
+		runtime.WithIncomingHeaderMatcher(func(key string) (string, bool) {
+			if gatewayHeaders[strings.ToLower(key)] {
+				return strings.ToLower(key), true
+			}
+			return runtime.DefaultHeaderMatcher(key)
+		}),
+		runtime.WithOutgoingHeaderMatcher(func(key string) (string, bool) {
+			if gatewayHeaders[strings.ToLower(key)] {
+				return key, true
+			}
+			return runtime.MetadataHeaderPrefix + key, true
+		}),
+	)
+}
+
+// InitGRPCGateway registers REST handlers of gRPC service, e.g. pb.RegisterNewsServiceHandlerFromEndpoint.
+// Gateway calls gRPC server of this service, so requests pass all interceptors.
+func InitGRPCGateway(register func(context.Context, *runtime.ServeMux, string, []grpc.DialOption) error) error {
+
+	if !viper.GetBool("app.http.enabled") {
+		return nil
+	}
+
+	creds, err := gatewayCredentials()
+	if err != nil {
+		return errors.Wrap(err, "cannot init gateway credentials")
+	}
+
+	endpoint := "localhost:" + viper.GetString("app.grpc.port")
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	err = register(context.Background(), grpcMux, endpoint, opts)
+	if err != nil {
+		return errors.Wrap(err, "cannot register gateway handler")
+	}
+	return nil
+}
+
+// gatewayCredentials trusts own certificate of gRPC server and uses client certificate if it is required
+func gatewayCredentials() (credentials.TransportCredentials, error) {
+
+	if !tlsEnabled() {
+		return insecure.NewCredentials(), nil
+	}
+
+	pem, err := os.ReadFile(viper.GetString("app.grpc.tls.cert"))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read server certificate")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("server certificate has no PEM certificates")
+	}
+
+	config := &tls.Config{
+		RootCAs:    pool,
+		ServerName: viper.GetString("app.http.grpc_server_name"),
+		MinVersion: tls.VersionTLS12,
+	}
+
+	cert, key := viper.GetString("app.http.client_cert"), viper.GetString("app.http.client_key")
+	if cert != "" && key != "" {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot load gateway client certificate")
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+
+	return credentials.NewTLS(config), nil
+}
+
+// HandleHTTP adds handler to HTTP server besides gateway, e.g. for health checks
+func HandleHTTP(pattern string, handler http.Handler) {
+	httpMux.Handle(pattern, handler)
+}
+
+// HandleDocs serves API docs on /docs/, without app.debug API key is required as password of basic auth
+func HandleDocs(handler http.Handler) {
+	if !viper.GetBool("app.http.enabled") {
+		return
+	}
+	if !viper.GetBool("app.debug") {
+		handler = requireApiKey(handler)
+	}
+	HandleHTTP("/docs/", handler)
+}
+
+func requireApiKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, key, ok := r.BasicAuth()
+		if !ok {
+			key = r.Header.Get("Authorization")
+		}
+		if _, err := matchApiKey(key); err != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="docs"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RunHTTPServer serves probes and metrics on app.http.port, gateway is added only if app.http.enabled
+func RunHTTPServer() {
+
+	gateway := viper.GetBool("app.http.enabled")
+	if gateway {
+		httpMux.Handle("/", grpcMux)
+	}
+
+	lis, err := net.Listen("tcp", ":"+viper.GetString("app.http.port"))
+	if err != nil {
+		log.Fatal("%v", err)
+	}
+
+	server := &http.Server{
+		Handler:           httpMux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	httpMx.Lock()
+	httpServer = server
+	httpMx.Unlock()
+	if gateway {
+		log.Info("HTTP gateway listening at %v", lis.Addr())
+	} else {
+		log.Info("HTTP server of probes and metrics listening at %v", lis.Addr())
+	}
+
+	if err := server.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal("%v", err)
+	}
+}
+
+func runningHTTPServer() *http.Server {
+	httpMx.Lock()
+	defer httpMx.Unlock()
+	return httpServer
+}
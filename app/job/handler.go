@@ -1,21 +1,38 @@
 package job
 
 import (
+	"context"
+	"github.com/go-co-op/gocron"
+	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 	"go.uber.org/dig"
+	"microservice/app/metrics"
+	"microservice/app/tracing"
+	"path"
 	"reflect"
 	"runtime"
+	"time"
 )
 
 func NewJob(job interface{}, scheduleUTC string) {
-	newJob(job, scheduleUTC, false)
+	newJob(job, s.Cron(scheduleUTC), false)
 }
 
 func NewJobWithImmediately(job interface{}, scheduleUTC string) {
-	newJob(job, scheduleUTC, true)
+	newJob(job, s.Cron(scheduleUTC), true)
 }
 
-func newJob(job interface{}, scheduleUTC string, immediately bool) {
+// NewIntervalJob runs job every interval (cron cannot schedule more often than once a minute)
+func NewIntervalJob(job interface{}, interval time.Duration) {
+	newJob(job, s.Every(interval), false)
+}
+
+// NewSystemIntervalJob runs job every interval even if jobs.enabled is false, e.g. outbox relay
+func NewSystemIntervalJob(job interface{}, interval time.Duration) {
+	newJob(job, system.Every(interval), false)
+}
+
+func newJob(job interface{}, schedule *gocron.Scheduler, immediately bool) {
 
 	name := runtime.FuncForPC(reflect.ValueOf(job).Pointer()).Name()
 
@@ -29,7 +46,11 @@ func newJob(job interface{}, scheduleUTC string, immediately bool) {
 
 		runnable := func() error {
 			log.Debug("Started %s", name)
-			err := j.Run()
+			start := time.Now()
+			ctx, span := tracing.Start(runCtx, "job "+path.Base(name))
+			err := j.Run(ctx)
+			tracing.End(span, err)
+			metrics.ObserveJob(path.Base(name), time.Since(start), err)
 			if err != nil {
 				return err
 			}
@@ -37,7 +58,7 @@ func newJob(job interface{}, scheduleUTC string, immediately bool) {
 			return nil
 		}
 
-		_, err := s.Cron(scheduleUTC).Do(runnable)
+		_, err := schedule.Do(runnable)
 		if err != nil {
 			log.Fatal("cannot DO cron %s: %s", name, err.Error())
 		}
@@ -54,6 +75,8 @@ func newJob(job interface{}, scheduleUTC string, immediately bool) {
 
 func Start() error {
 
+	system.StartAsync()
+
 	enabled := viper.GetBool("jobs.enabled")
 	if !enabled {
 		return nil
@@ -71,3 +94,26 @@ func Start() error {
 	s.StartAsync()
 	return nil
 }
+
+// Stop waits for running jobs, they are cancelled when ctx is done
+func Stop(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		system.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		cancelRuns()
+		<-stopped
+		return errors.New("jobs were cancelled on shutdown")
+	}
+}
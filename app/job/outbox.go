@@ -0,0 +1,200 @@
+package job
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math"
+	"microservice/app/core"
+	"microservice/app/kafka"
+	"microservice/app/tracing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	trmsql "github.com/avito-tech/go-transaction-manager/sql"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// Key of postgres advisory lock, only one relay should send messages at once to keep their order
+const outboxLockId = 7243001
+
+type outboxMessage struct {
+	id       int64
+	topic    string
+	key      string
+	payload  []byte
+	headers  map[string]string
+	attempts int
+}
+
+// OutboxRelayJob sends messages which were written to outbox table in transactions of repositories
+type OutboxRelayJob struct {
+	log    core.Logger
+	db     *sql.DB
+	topics map[string]*kafka.KafkaTopic[[]byte]
+
+	batch      int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	retention  time.Duration
+}
+
+func NewOutboxRelayJob(log core.Logger, db *sql.DB) *OutboxRelayJob {
+	j := &OutboxRelayJob{
+		log:        log,
+		db:         db,
+		topics:     make(map[string]*kafka.KafkaTopic[[]byte]),
+		batch:      viper.GetInt("kafka.outbox.batch"),
+		minBackoff: viper.GetDuration("kafka.outbox.min_backoff"),
+		maxBackoff: viper.GetDuration("kafka.outbox.max_backoff"),
+		retention:  viper.GetDuration("kafka.outbox.retention"),
+	}
+	if j.batch <= 0 {
+		j.batch = 100
+	}
+	if j.minBackoff <= 0 {
+		j.minBackoff = time.Second
+	}
+	if j.maxBackoff <= 0 {
+		j.maxBackoff = 5 * time.Minute
+	}
+	if j.retention <= 0 {
+		j.retention = 24 * time.Hour
+	}
+	return j
+}
+
+func (j *OutboxRelayJob) Run(ctx context.Context) error {
+
+	if !kafka.Enabled() {
+		return nil
+	}
+
+	sqlTx, err := j.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "cannot begin outbox transaction")
+	}
+	defer sqlTx.Rollback()
+	tx := tracing.SQL(sqlTx)
+
+	var locked bool
+	err = tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", outboxLockId).Scan(&locked)
+	if err != nil {
+		return errors.Wrap(err, "cannot lock outbox")
+	}
+	if !locked {
+		return nil // another instance relays now
+	}
+
+	// Every round sends the next message of every aggregate, till batch is sent or nothing is due
+	sent := 0
+	for sent < j.batch {
+		messages, err := j.fetch(ctx, tx, j.batch-sent)
+		if err != nil {
+			return err
+		}
+
+		roundSent := 0
+		for _, m := range messages {
+			sendErr := j.send(ctx, m)
+			if sendErr != nil {
+				j.log.ErrorWrap(sendErr, "cannot relay outbox message %d to topic %s (attempt %d)", m.id, m.topic, m.attempts+1)
+
+				_, err = tx.ExecContext(ctx, `UPDATE outbox SET attempts = attempts + 1, last_error = $2,
+						next_attempt_at = now() + $3 * interval '1 second' WHERE id = $1`,
+					m.id, sendErr.Error(), j.backoff(m.attempts).Seconds())
+				if err != nil {
+					return errors.Wrap(err, "cannot mark outbox message as failed")
+				}
+				continue
+			}
+
+			_, err = tx.ExecContext(ctx, `UPDATE outbox SET attempts = attempts + 1, last_error = NULL,
+						delivered_at = now() WHERE id = $1`, m.id)
+			if err != nil {
+				return errors.Wrap(err, "cannot mark outbox message as delivered")
+			}
+			roundSent++
+		}
+
+		sent += roundSent
+		if roundSent == 0 {
+			break
+		}
+	}
+
+	// Cleanup
+	_, err = tx.ExecContext(ctx, `DELETE FROM outbox WHERE delivered_at < now() - $1 * interval '1 second'`,
+		j.retention.Seconds())
+	if err != nil {
+		return errors.Wrap(err, "cannot cleanup outbox")
+	}
+
+	err = sqlTx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "cannot commit outbox transaction")
+	}
+
+	if sent > 0 {
+		j.log.Debug("Outbox relay sent %d messages", sent)
+	}
+	return nil
+}
+
+// fetch returns the first undelivered message of every aggregate (topic and key) if it is due,
+// message cannot overtake previous one and aggregate which waits for retry doesn't take batch of others
+func (j *OutboxRelayJob) fetch(ctx context.Context, tx trmsql.Tr, limit int) ([]*outboxMessage, error) {
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, topic, message_key, payload, headers, attempts FROM (
+							SELECT DISTINCT ON (topic, message_key) id, topic, message_key, payload, headers, attempts, next_attempt_at
+							FROM outbox WHERE delivered_at IS NULL ORDER BY topic, message_key, id
+						) head WHERE next_attempt_at <= now() ORDER BY id LIMIT $1`, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot fetch outbox messages")
+	}
+	defer rows.Close()
+
+	var messages []*outboxMessage
+	for rows.Next() {
+		var m outboxMessage
+		var headers []byte
+		err := rows.Scan(&m.id, &m.topic, &m.key, &m.payload, &headers, &m.attempts)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot scan outbox message")
+		}
+		if err := json.Unmarshal(headers, &m.headers); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse headers of outbox message %d", m.id)
+		}
+		messages = append(messages, &m)
+	}
+	return messages, rows.Err()
+}
+
+func (j *OutboxRelayJob) send(ctx context.Context, m *outboxMessage) error {
+
+	topic, ok := j.topics[m.topic]
+	if !ok {
+		var err error
+		topic, err = kafka.Topic[[]byte](m.topic)
+		if err != nil {
+			return errors.Wrapf(err, "cannot init topic %s", m.topic)
+		}
+		j.topics[m.topic] = topic
+	}
+
+	headers := make([]sarama.RecordHeader, 0, len(m.headers))
+	for k, v := range m.headers {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(k), Value: []byte(v)})
+	}
+
+	return topic.ProduceWithKey(ctx, m.key, m.payload, headers...)
+}
+
+func (j *OutboxRelayJob) backoff(attempts int) time.Duration {
+	d := time.Duration(float64(j.minBackoff) * math.Pow(2, float64(attempts)))
+	if d > j.maxBackoff || d <= 0 {
+		return j.maxBackoff
+	}
+	return d
+}
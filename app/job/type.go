@@ -1,6 +1,7 @@
 package job
 
 import (
+	"context"
 	"github.com/go-co-op/gocron"
 	"go.uber.org/dig"
 	"microservice/app/core"
@@ -11,11 +12,16 @@ var (
 	log             core.Logger
 	di              *dig.Container
 	s               *gocron.Scheduler
+	system          *gocron.Scheduler // jobs of app itself, they are not turned off by jobs.enabled
 	immediatelyJobs map[string]func() error
+
+	// Context of job runs, it is cancelled if jobs are not finished in time on Stop
+	runCtx, cancelRuns = context.WithCancel(context.Background())
 )
 
+// Job is run with context of its span, so queries and messages of the run are in one trace
 type Job interface {
-	Run() error
+	Run(ctx context.Context) error
 }
 
 func Init(logger core.Logger, di_ *dig.Container) error {
@@ -24,6 +30,9 @@ func Init(logger core.Logger, di_ *dig.Container) error {
 	s = gocron.NewScheduler(time.UTC)
 	s.SingletonModeAll()
 	s.WaitForScheduleAll()
+	system = gocron.NewScheduler(time.UTC)
+	system.SingletonModeAll()
+	system.WaitForScheduleAll()
 	immediatelyJobs = make(map[string]func() error)
 	return nil
 }
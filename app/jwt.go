@@ -0,0 +1,282 @@
+package app
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"microservice/app/core"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	jwtVerifier *JWTVerifier
+)
+
+// JWTVerifier checks bearer tokens with keys from local JWKS file
+type JWTVerifier struct {
+	keys      map[string]interface{}
+	issuer    string
+	audience  string
+	userClaim string
+	roleClaim string
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+
+	// Symmetric (HS256)
+	K string `json:"k"`
+}
+
+func initJWT() error {
+
+	enabled := viper.GetBool("app.auth.jwt.enabled")
+	if !enabled {
+		return nil
+	}
+
+	keys, err := loadJWKS(viper.GetString("app.auth.jwt.jwks"))
+	if err != nil {
+		return errors.Wrap(err, "cannot load jwks")
+	}
+
+	userClaim := viper.GetString("app.auth.jwt.user_claim")
+	if userClaim == "" {
+		userClaim = "user_id"
+	}
+	roleClaim := viper.GetString("app.auth.jwt.role_claim")
+	if roleClaim == "" {
+		roleClaim = "role"
+	}
+
+	jwtVerifier = &JWTVerifier{
+		keys:      keys,
+		issuer:    viper.GetString("app.auth.jwt.issuer"),
+		audience:  viper.GetString("app.auth.jwt.audience"),
+		userClaim: userClaim,
+		roleClaim: roleClaim,
+	}
+	log.Info("JWT auth enabled with %d keys", len(keys))
+	return nil
+}
+
+func loadJWKS(file string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read jwks file %s", file)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse jwks file %s", file)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, errors.Wrapf(err, "incorrect key #%d (kid=%s) in jwks", i, k.Kid)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.Errorf("no signing keys in jwks file %s", file)
+	}
+	return keys, nil
+}
+
+func (k *jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot decode n")
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot decode e")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, errors.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot decode x")
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot decode y")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot decode k")
+		}
+		return secret, nil
+	default:
+		return nil, errors.Errorf("unsupported key type %s", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// Verify parses token and returns identity from its claims
+func (v *JWTVerifier) Verify(token string) (*core.Identity, error) {
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"HS256", "RS256", "ES256"}),
+		jwt.WithExpirationRequired(),
+	}
+	if v.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(v.issuer))
+	}
+	if v.audience != "" {
+		opts = append(opts, jwt.WithAudience(v.audience))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, v.keyFunc, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid token")
+	}
+
+	userId, err := claimInt64(claims[v.userClaim])
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid claim %s", v.userClaim)
+	}
+
+	role := core.RoleUser
+	if raw, ok := claims[v.roleClaim]; ok {
+		role, err = claimRole(raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid claim %s", v.roleClaim)
+		}
+	}
+
+	return &core.Identity{
+		UserId:    userId,
+		Role:      role,
+		Principal: "user:" + strconv.FormatInt(userId, 10),
+	}, nil
+}
+
+func (v *JWTVerifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	var key interface{}
+	if kid != "" {
+		key = v.keys[kid]
+	} else if len(v.keys) == 1 {
+		for _, k := range v.keys {
+			key = k
+		}
+	}
+	if key == nil {
+		return nil, errors.Errorf("unknown key id %s", kid)
+	}
+
+	// Key type should match algorithm, otherwise RS256 key could be used as HS256 secret
+	switch token.Method.Alg() {
+	case "HS256":
+		if _, ok := key.([]byte); ok {
+			return key, nil
+		}
+	case "RS256":
+		if _, ok := key.(*rsa.PublicKey); ok {
+			return key, nil
+		}
+	case "ES256":
+		if _, ok := key.(*ecdsa.PublicKey); ok {
+			return key, nil
+		}
+	}
+	return nil, errors.Errorf("key %s cannot be used with %s", kid, token.Method.Alg())
+}
+
+func claimInt64(raw interface{}) (int64, error) {
+	switch v := raw.(type) {
+	case float64:
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case nil:
+		return -1, errors.New("claim is required")
+	default:
+		return -1, errors.Errorf("unsupported claim type %T", raw)
+	}
+}
+
+func claimRole(raw interface{}) (core.AccessRole, error) {
+	switch v := raw.(type) {
+	case float64:
+		return core.AccessRole(v), nil
+	case string:
+		return core.ParseAccessRole(v)
+	default:
+		return core.RoleGuest, errors.Errorf("unsupported claim type %T", raw)
+	}
+}
+
+// Interceptor
+
+func jwtAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+
+	var token string
+	m, ok := metadata.FromIncomingContext(ctx)
+	if ok {
+		tokens := m.Get("Authorization")
+		if len(tokens) > 0 && strings.HasPrefix(tokens[0], "Bearer ") {
+			token = strings.TrimPrefix(tokens[0], "Bearer ")
+		}
+	}
+	if token == "" {
+		return nil, status.Errorf(codes.Unauthenticated, "DENIED access without bearer token! %s", info.FullMethod)
+	}
+
+	identity, err := jwtVerifier.Verify(token)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "DENIED access with bad token! %s: %s", info.FullMethod, err.Error())
+	}
+
+	return handler(core.WithIdentity(ctx, identity), req)
+}
@@ -0,0 +1,228 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/trace"
+	"microservice/app/tracing"
+)
+
+// DeliveryCallback is called with nil error when message was acknowledged by brokers
+type DeliveryCallback func(msg *sarama.ProducerMessage, err error)
+
+// asyncProducer batches messages by kafka.producer settings, it is created on first use
+// with its own client, so linger doesn't slow down sync producer
+type asyncProducer struct {
+	mx       sync.Mutex
+	producer sarama.AsyncProducer
+	closed   bool
+
+	// In-flight messages and dispatchers of results
+	inflight sync.WaitGroup
+	done     sync.WaitGroup
+}
+
+// applyProducerConfig sets compression for all producers and returns config for async producer
+func applyProducerConfig(config *sarama.Config) (*sarama.Config, error) {
+
+	compression := viper.GetString("kafka.producer.compression")
+	if compression == "" {
+		compression = "none"
+	}
+	err := config.Producer.Compression.UnmarshalText([]byte(compression))
+	if err != nil {
+		return nil, errors.Wrap(err, "incorrect kafka.producer.compression")
+	}
+
+	async := *config
+	async.Producer.Flush.Messages = viper.GetInt("kafka.producer.batch_messages")
+	async.Producer.Flush.Bytes = viper.GetInt("kafka.producer.batch_bytes")
+	async.Producer.Flush.Frequency = viper.GetDuration("kafka.producer.linger")
+	if err := async.Validate(); err != nil {
+		return nil, errors.Wrap(err, "incorrect kafka.producer config")
+	}
+	return &async, nil
+}
+
+// get is called under lock
+func (p *asyncProducer) get() (sarama.AsyncProducer, error) {
+	if p.closed {
+		return nil, errors.New("kafka producer is closed")
+	}
+	if p.producer != nil {
+		return p.producer, nil
+	}
+
+	producer, err := sarama.NewAsyncProducer(k.brokers, k.asyncConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create async kafka producer")
+	}
+	p.producer = producer
+
+	p.done.Add(2)
+	go func() {
+		defer p.done.Done()
+		for msg := range producer.Successes() {
+			p.deliver(msg, nil)
+		}
+	}()
+	go func() {
+		defer p.done.Done()
+		for err := range producer.Errors() {
+			p.deliver(err.Msg, err.Err)
+		}
+	}()
+
+	return producer, nil
+}
+
+func (p *asyncProducer) deliver(msg *sarama.ProducerMessage, err error) {
+	defer p.inflight.Done()
+
+	callback, _ := msg.Metadata.(DeliveryCallback)
+	if callback != nil {
+		callback(msg, err)
+		return
+	}
+	if err != nil {
+		logger.ErrorWrap(err, "KAFKA: cannot deliver message to topic %s", msg.Topic)
+	}
+}
+
+// send is locked with close, so input is never written after producer was closed
+func (p *asyncProducer) send(msg *sarama.ProducerMessage) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	producer, err := p.get()
+	if err != nil {
+		return err
+	}
+	p.inflight.Add(1)
+	producer.Input() <- msg
+	return nil
+}
+
+// close waits in-flight messages not longer than timeout
+func (p *asyncProducer) close(timeout time.Duration) error {
+	p.mx.Lock()
+	producer := p.producer
+	p.closed = true
+	p.mx.Unlock()
+
+	if producer == nil {
+		return nil
+	}
+
+	// Flush and closing share one deadline
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	flushed := make(chan struct{})
+	go func() {
+		p.inflight.Wait()
+		close(flushed)
+	}()
+
+	var err error
+	select {
+	case <-flushed:
+	case <-deadline.C:
+		err = errors.Errorf("kafka producer was not flushed in %s", timeout)
+	}
+
+	producer.AsyncClose()
+	if err != nil {
+		return err
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		p.done.Wait()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		return nil
+	case <-deadline.C:
+		return errors.Errorf("kafka producer was not closed in %s", timeout)
+	}
+}
+
+// ProduceAsync sends obj without waiting for brokers, callback (may be nil) gets result.
+// Without callback failed delivery is only logged. Error is returned if obj cannot be encoded.
+func (t *KafkaTopic[T]) ProduceAsync(ctx context.Context, key string, obj T, callback DeliveryCallback, headers ...sarama.RecordHeader) error {
+
+	// Span covers encoding and queueing, delivery is reported to callback
+	ctx, span := t.startSpan(ctx, trace.SpanKindProducer)
+	msg, extra, err := t.decode(obj)
+	if err != nil {
+		tracing.End(span, err)
+		return err
+	}
+
+	message := &sarama.ProducerMessage{
+		Topic:   t.topic,
+		Value:   sarama.ByteEncoder(msg),
+		Headers: withTraceHeaders(ctx, append(headers, extra...)),
+	}
+	if key != "" {
+		message.Key = sarama.StringEncoder(key)
+	}
+	if callback != nil {
+		message.Metadata = callback
+	}
+
+	err = k.async.send(message)
+	tracing.End(span, err)
+	return err
+}
+
+// Close stops subscriptions, flushes async messages and closes connections, call it on shutdown
+func Close() error {
+	if k == nil {
+		return nil
+	}
+
+	// Handlers are stopped before producers, they may produce too
+	k.groupsMx.Lock()
+	for _, group := range k.groups {
+		if err := group.Close(); err != nil {
+			logger.ErrorWrap(err, "KAFKA: cannot close consumer group")
+		}
+	}
+	k.groups = nil
+	for _, pc := range k.pollers {
+		if err := pc.Close(); err != nil {
+			logger.ErrorWrap(err, "KAFKA: cannot close partition consumer")
+		}
+	}
+	k.pollers = nil
+	k.groupsMx.Unlock()
+
+	timeout := viper.GetDuration("kafka.producer.flush_timeout")
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	err := k.async.close(timeout)
+	if err != nil {
+		logger.ErrorWrap(err, "KAFKA: some messages may be lost")
+	}
+
+	if err := k.producer.Close(); err != nil {
+		logger.ErrorWrap(err, "KAFKA: cannot close producer")
+	}
+	if err := k.consumer.Close(); err != nil {
+		logger.ErrorWrap(err, "KAFKA: cannot close consumer")
+	}
+	if err := k.client.Close(); err != nil {
+		logger.ErrorWrap(err, "KAFKA: cannot close client")
+	}
+	return err
+}
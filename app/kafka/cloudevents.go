@@ -0,0 +1,201 @@
+package kafka
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// CloudEvents modes of kafka protocol binding
+const (
+	CloudEventsBinary     = "binary"
+	CloudEventsStructured = "structured"
+)
+
+const (
+	cloudEventsSpecVersion = "1.0"
+	cloudEventsContentType = "application/cloudevents+json"
+	headerContentType      = "content-type"
+	headerCloudEventPrefix = "ce_"
+)
+
+// CloudEvent is envelope of structured mode, in binary mode attributes are ce_* headers
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	Id              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            *time.Time      `json:"time,omitempty"` // pointer, zero time is not valid for CloudEvents
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	DataBase64      string          `json:"data_base64,omitempty"`
+}
+
+type cloudEventsEncoder struct {
+	source    string
+	eventType string
+	mode      string
+	data      Encoder
+}
+
+// CloudEventsEncoder wraps data encoder (JSON if nil) into CloudEvents envelope.
+// Both modes are accepted while consuming, mode is used for producing.
+func CloudEventsEncoder(source, eventType, mode string, data Encoder) (HeaderEncoder, error) {
+	if source == "" || eventType == "" {
+		return nil, errors.New("source and type are required for cloudevents")
+	}
+	if mode == "" {
+		mode = CloudEventsBinary
+	}
+	if mode != CloudEventsBinary && mode != CloudEventsStructured {
+		return nil, errors.Errorf("unknown cloudevents mode %s", mode)
+	}
+	if data == nil {
+		data = JsonEncoder()
+	}
+	return &cloudEventsEncoder{
+		source:    source,
+		eventType: eventType,
+		mode:      mode,
+		data:      data,
+	}, nil
+}
+
+func (e *cloudEventsEncoder) contentType() string {
+	switch e.data.(type) {
+	case *jsonEncoder:
+		return "application/json"
+	case *protoEncoder:
+		return "application/protobuf"
+	case *stringEncoder:
+		return "text/plain"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Encode without headers expects structured mode
+func (e *cloudEventsEncoder) Encode(in []byte, out interface{}) error {
+	return e.EncodeWithHeaders(in, nil, out)
+}
+
+func (e *cloudEventsEncoder) EncodeWithHeaders(in []byte, headers []*sarama.RecordHeader, out interface{}) error {
+
+	attributes := make(map[string]string)
+	for _, h := range headers {
+		if h != nil {
+			attributes[strings.ToLower(string(h.Key))] = string(h.Value)
+		}
+	}
+
+	// Binary mode
+	if version, ok := attributes[headerCloudEventPrefix+"specversion"]; ok {
+		if version != cloudEventsSpecVersion {
+			return errors.Errorf("unsupported cloudevents version %s", version)
+		}
+		return e.data.Encode(in, out)
+	}
+
+	// Structured mode
+	if ct, ok := attributes[headerContentType]; ok && !strings.HasPrefix(ct, cloudEventsContentType) {
+		return errors.Errorf("message is not cloudevent, content type is %s", ct)
+	}
+	var event CloudEvent
+	if err := json.Unmarshal(in, &event); err != nil {
+		return errors.Wrap(err, "cannot parse cloudevent")
+	}
+	if event.SpecVersion != cloudEventsSpecVersion {
+		return errors.Errorf("unsupported cloudevents version %s", event.SpecVersion)
+	}
+
+	if event.DataBase64 != "" {
+		data, err := base64.StdEncoding.DecodeString(event.DataBase64)
+		if err != nil {
+			return errors.Wrap(err, "cannot decode data_base64 of cloudevent")
+		}
+		return e.data.Encode(data, out)
+	}
+
+	data := []byte(event.Data)
+	if _, ok := e.data.(*stringEncoder); ok {
+		// Text is JSON string in envelope
+		var s string
+		if err := json.Unmarshal(event.Data, &s); err != nil {
+			return errors.Wrap(err, "cannot parse data of cloudevent")
+		}
+		data = []byte(s)
+	}
+	return e.data.Encode(data, out)
+}
+
+// Decode without headers always uses structured mode
+func (e *cloudEventsEncoder) Decode(t interface{}) ([]byte, error) {
+	value, _, err := e.structured(t)
+	return value, err
+}
+
+func (e *cloudEventsEncoder) DecodeWithHeaders(t interface{}) ([]byte, []sarama.RecordHeader, error) {
+	if e.mode == CloudEventsStructured {
+		return e.structured(t)
+	}
+
+	data, err := e.data.Decode(t)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := func(key, value string) sarama.RecordHeader {
+		return sarama.RecordHeader{Key: []byte(key), Value: []byte(value)}
+	}
+	headers := []sarama.RecordHeader{
+		header(headerCloudEventPrefix+"specversion", cloudEventsSpecVersion),
+		header(headerCloudEventPrefix+"id", uuid.NewString()),
+		header(headerCloudEventPrefix+"source", e.source),
+		header(headerCloudEventPrefix+"type", e.eventType),
+		header(headerCloudEventPrefix+"time", time.Now().UTC().Format(time.RFC3339Nano)),
+		header(headerContentType, e.contentType()),
+	}
+	return data, headers, nil
+}
+
+func (e *cloudEventsEncoder) structured(t interface{}) ([]byte, []sarama.RecordHeader, error) {
+
+	data, err := e.data.Decode(t)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now().UTC()
+	event := CloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		Id:              uuid.NewString(),
+		Source:          e.source,
+		Type:            e.eventType,
+		Time:            &now,
+		DataContentType: e.contentType(),
+	}
+	switch e.data.(type) {
+	case *jsonEncoder:
+		event.Data = data
+	case *stringEncoder:
+		event.Data, err = json.Marshal(string(data))
+		if err != nil {
+			return nil, nil, err
+		}
+	default:
+		event.DataBase64 = base64.StdEncoding.EncodeToString(data)
+	}
+
+	value, err := json.Marshal(event)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot marshal cloudevent")
+	}
+	headers := []sarama.RecordHeader{
+		{Key: []byte(headerContentType), Value: []byte(cloudEventsContentType + "; charset=UTF-8")},
+	}
+	return value, headers, nil
+}
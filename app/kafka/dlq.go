@@ -0,0 +1,361 @@
+package kafka
+
+import (
+	"context"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// Headers of dead-lettered message
+const (
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderError             = "x-error"
+	HeaderAttempts          = "x-attempts"
+)
+
+// RetryPolicy of failed handler, after the last attempt message is sent to <topic>.dlq
+type RetryPolicy struct {
+	Topic      string        `mapstructure:"topic"`
+	Attempts   int           `mapstructure:"attempts"`
+	MinBackoff time.Duration `mapstructure:"min_backoff"`
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+}
+
+// ListDLQ stops reading partition if there are no messages for this time
+const dlqIdleTimeout = 5 * time.Second
+
+func DLQTopic(topic string) string {
+	return topic + ".dlq"
+}
+
+// retryPolicy for topic from kafka.retry config
+func retryPolicy(topic string) (RetryPolicy, error) {
+	policy := RetryPolicy{
+		Attempts:   viper.GetInt("kafka.retry.attempts"),
+		MinBackoff: viper.GetDuration("kafka.retry.min_backoff"),
+		MaxBackoff: viper.GetDuration("kafka.retry.max_backoff"),
+	}
+
+	var topics []RetryPolicy
+	if err := viper.UnmarshalKey("kafka.retry.topics", &topics); err != nil {
+		return policy, errors.Wrap(err, "cannot parse kafka.retry.topics")
+	}
+	for _, item := range topics {
+		if item.Topic != topic {
+			continue
+		}
+		if item.Attempts != 0 {
+			policy.Attempts = item.Attempts
+		}
+		if item.MinBackoff != 0 {
+			policy.MinBackoff = item.MinBackoff
+		}
+		if item.MaxBackoff != 0 {
+			policy.MaxBackoff = item.MaxBackoff
+		}
+	}
+
+	return policy.withDefaults(), nil
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.Attempts <= 0 {
+		p.Attempts = 1
+	}
+	if p.MinBackoff <= 0 {
+		p.MinBackoff = time.Second
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 30 * time.Second
+	}
+	return p
+}
+
+// Backoff before attempt (starts from 1)
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	d := time.Duration(float64(p.MinBackoff) * math.Pow(2, float64(attempt-1)))
+	if d > p.MaxBackoff || d <= 0 {
+		return p.MaxBackoff
+	}
+	return d
+}
+
+// sendToDLQ sends message as is with original key and headers, plus headers about failure
+func sendToDLQ(message *sarama.ConsumerMessage, cause error, attempts int) error {
+
+	headers := make([]sarama.RecordHeader, 0, len(message.Headers)+5)
+	for _, h := range message.Headers {
+		if h != nil && !isDLQHeader(string(h.Key)) {
+			headers = append(headers, *h)
+		}
+	}
+	headers = append(headers,
+		sarama.RecordHeader{Key: []byte(HeaderOriginalTopic), Value: []byte(message.Topic)},
+		sarama.RecordHeader{Key: []byte(HeaderOriginalPartition), Value: []byte(strconv.Itoa(int(message.Partition)))},
+		sarama.RecordHeader{Key: []byte(HeaderOriginalOffset), Value: []byte(strconv.FormatInt(message.Offset, 10))},
+		sarama.RecordHeader{Key: []byte(HeaderError), Value: []byte(cause.Error())},
+		sarama.RecordHeader{Key: []byte(HeaderAttempts), Value: []byte(strconv.Itoa(attempts))},
+	)
+
+	dlq := &sarama.ProducerMessage{
+		Topic:   DLQTopic(message.Topic),
+		Value:   sarama.ByteEncoder(message.Value),
+		Headers: headers,
+	}
+	if message.Key != nil {
+		dlq.Key = sarama.ByteEncoder(message.Key)
+	}
+
+	_, _, err := k.producer.SendMessage(dlq)
+	if err != nil {
+		return errors.Wrapf(err, "cannot send message to %s", dlq.Topic)
+	}
+	logger.Warn("KAFKA: message was sent to %s (partition=%d, offset=%d) %s: %s",
+		dlq.Topic, message.Partition, message.Offset, traceString(message), cause.Error())
+	return nil
+}
+
+// sendToDLQUntilDone doesn't give up, otherwise message would be lost
+func sendToDLQUntilDone(ctx context.Context, policy RetryPolicy, message *sarama.ConsumerMessage, cause error, attempts int) error {
+	for i := 1; ; i++ {
+		err := sendToDLQ(message, cause, attempts)
+		if err == nil {
+			return nil
+		}
+		logger.ErrorWrap(err, "KAFKA: cannot dead-letter message of topic %s", message.Topic)
+
+		select {
+		case <-time.After(policy.Backoff(i)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func isDLQHeader(key string) bool {
+	switch key {
+	case HeaderOriginalTopic, HeaderOriginalPartition, HeaderOriginalOffset, HeaderError, HeaderAttempts:
+		return true
+	}
+	return false
+}
+
+// DeadLetter is message from <topic>.dlq
+type DeadLetter struct {
+	Partition         int32
+	Offset            int64
+	Timestamp         time.Time
+	Key               []byte
+	Value             []byte
+	Headers           []*sarama.RecordHeader
+	OriginalTopic     string
+	OriginalPartition int32
+	OriginalOffset    int64
+	Error             string
+	Attempts          int
+}
+
+func newDeadLetter(message *sarama.ConsumerMessage) *DeadLetter {
+	d := &DeadLetter{
+		Partition: message.Partition,
+		Offset:    message.Offset,
+		Timestamp: message.Timestamp,
+		Key:       message.Key,
+		Value:     message.Value,
+	}
+	for _, h := range message.Headers {
+		if h == nil {
+			continue
+		}
+		value := string(h.Value)
+		switch string(h.Key) {
+		case HeaderOriginalTopic:
+			d.OriginalTopic = value
+		case HeaderOriginalPartition:
+			p, _ := strconv.Atoi(value)
+			d.OriginalPartition = int32(p)
+		case HeaderOriginalOffset:
+			d.OriginalOffset, _ = strconv.ParseInt(value, 10, 64)
+		case HeaderError:
+			d.Error = value
+		case HeaderAttempts:
+			d.Attempts, _ = strconv.Atoi(value)
+		default:
+			d.Headers = append(d.Headers, h)
+		}
+	}
+	return d
+}
+
+// ListDLQ reads messages which are in <topic>.dlq now, limit <= 0 means all
+func ListDLQ(topic string, limit int) ([]*DeadLetter, error) {
+	return readDLQ(topic, -1, limit, nil)
+}
+
+// readDLQ reads partition of <topic>.dlq (all if partition < 0) from offset in replayed store or from the oldest
+func readDLQ(topic string, partition int32, limit int, replayed OffsetStore) ([]*DeadLetter, error) {
+
+	dlq := DLQTopic(topic)
+	partitions, err := k.consumer.Partitions(dlq)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get partitions of topic %s", dlq)
+	}
+
+	var result []*DeadLetter
+	for _, p := range partitions {
+		if partition >= 0 && p != partition {
+			continue
+		}
+
+		oldest, err := k.client.GetOffset(dlq, p, sarama.OffsetOldest)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get oldest offset of %s/%d", dlq, p)
+		}
+		newest, err := k.client.GetOffset(dlq, p, sarama.OffsetNewest)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get newest offset of %s/%d", dlq, p)
+		}
+		if replayed != nil {
+			offset, err := replayed.Load(context.Background(), dlq, p)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot load replayed offset of %s/%d", dlq, p)
+			}
+			if offset != nil && *offset > oldest {
+				oldest = *offset
+			}
+		}
+		if newest <= oldest {
+			continue
+		}
+
+		pc, err := k.consumer.ConsumePartition(dlq, p, oldest)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot consume %s/%d", dlq, p)
+		}
+		result, err = readDLQPartition(pc, newest, limit, result)
+		pc.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read %s/%d", dlq, p)
+		}
+
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// readDLQPartition appends messages till newest offset. The last offset may be never delivered
+// (transaction marker, compacted record), so reading also stops after dlqIdleTimeout without messages.
+func readDLQPartition(pc sarama.PartitionConsumer, newest int64, limit int, result []*DeadLetter) ([]*DeadLetter, error) {
+	idle := time.NewTimer(dlqIdleTimeout)
+	defer idle.Stop()
+	errs := pc.Errors()
+	for {
+		select {
+		case message, ok := <-pc.Messages():
+			if !ok {
+				return result, nil
+			}
+			result = append(result, newDeadLetter(message))
+			if message.Offset >= newest-1 || (limit > 0 && len(result) >= limit) {
+				return result, nil
+			}
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(dlqIdleTimeout)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			return result, err
+		case <-idle.C:
+			return result, nil
+		}
+	}
+}
+
+// ReplayDLQ sends dead letters which were not replayed yet back to the original topic,
+// partition < 0 means all partitions of <topic>.dlq. Offset after replayed message is saved
+// in offset store of <topic>.dlq, so the next run continues after it.
+func ReplayDLQ(topic string, partition int32) (int, error) {
+
+	store, err := newOffsetStore(DLQTopic(topic))
+	if err != nil {
+		return 0, errors.Wrapf(err, "cannot create replay offset store of %s", DLQTopic(topic))
+	}
+	if closer, ok := store.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	letters, err := readDLQ(topic, partition, 0, store)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for _, letter := range letters {
+		if err := replay(topic, letter); err != nil {
+			return replayed, err
+		}
+		err := store.Save(context.Background(), DLQTopic(topic), letter.Partition, letter.Offset+1)
+		if err != nil {
+			return replayed, errors.Wrapf(err, "cannot save replayed offset %d/%d of %s", letter.Partition, letter.Offset, DLQTopic(topic))
+		}
+		replayed++
+	}
+	return replayed, nil
+}
+
+// ReplayDLQMessage sends one dead letter back to the original topic, replayed offset of ReplayDLQ is not changed
+func ReplayDLQMessage(topic string, partition int32, offset int64) error {
+
+	dlq := DLQTopic(topic)
+	pc, err := k.consumer.ConsumePartition(dlq, partition, offset)
+	if err != nil {
+		return errors.Wrapf(err, "cannot consume %s/%d from offset %d", dlq, partition, offset)
+	}
+	letters, err := readDLQPartition(pc, offset+1, 1, nil)
+	pc.Close()
+	if err != nil {
+		return errors.Wrapf(err, "cannot read %s/%d", dlq, partition)
+	}
+	// Offset may be compacted, then the next message is read
+	if len(letters) == 0 || letters[0].Offset != offset {
+		return errors.Errorf("message %d/%d is not found in %s", partition, offset, dlq)
+	}
+	return replay(topic, letters[0])
+}
+
+func replay(topic string, letter *DeadLetter) error {
+
+	headers := make([]sarama.RecordHeader, 0, len(letter.Headers))
+	for _, h := range letter.Headers {
+		headers = append(headers, *h)
+	}
+
+	message := &sarama.ProducerMessage{
+		Topic:   topic,
+		Value:   sarama.ByteEncoder(letter.Value),
+		Headers: headers,
+	}
+	if letter.Key != nil {
+		message.Key = sarama.ByteEncoder(letter.Key)
+	}
+
+	_, _, err := k.producer.SendMessage(message)
+	if err != nil {
+		return errors.Wrapf(err, "cannot replay message %d/%d of %s", letter.Partition, letter.Offset, DLQTopic(topic))
+	}
+	return nil
+}
@@ -2,7 +2,11 @@ package kafka
 
 import (
 	"encoding/json"
+	"reflect"
+
+	"github.com/Shopify/sarama"
 	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
 )
 
 type Encoder interface {
@@ -10,6 +14,13 @@ type Encoder interface {
 	Decode(interface{}) ([]byte, error)
 }
 
+// HeaderEncoder also reads and writes message headers, e.g. CloudEvents in binary mode
+type HeaderEncoder interface {
+	Encoder
+	EncodeWithHeaders(in []byte, headers []*sarama.RecordHeader, out interface{}) error
+	DecodeWithHeaders(interface{}) ([]byte, []sarama.RecordHeader, error)
+}
+
 type jsonEncoder struct{}
 
 func (*jsonEncoder) Encode(in []byte, out interface{}) error {
@@ -65,6 +76,41 @@ func (*byteEncoder) Decode(t interface{}) ([]byte, error) {
 	}
 }
 
+type protoEncoder struct{}
+
+// Encode accepts proto.Message or pointer to it (*T of Topic[*pb.Message]), nil message is allocated
+func (*protoEncoder) Encode(in []byte, out interface{}) error {
+	if m, ok := out.(proto.Message); ok {
+		return proto.Unmarshal(in, m)
+	}
+
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Pointer {
+		return errors.Errorf("incorrect type %T for proto encoder", out)
+	}
+	elem := v.Elem()
+	if elem.IsNil() {
+		elem.Set(reflect.New(elem.Type().Elem()))
+	}
+	m, ok := elem.Interface().(proto.Message)
+	if !ok {
+		return errors.Errorf("incorrect type %T for proto encoder", out)
+	}
+	return proto.Unmarshal(in, m)
+}
+
+func (*protoEncoder) Decode(t interface{}) ([]byte, error) {
+	m, ok := t.(proto.Message)
+	if !ok {
+		return nil, errors.Errorf("incorrect type %T for proto decoder", t)
+	}
+	return proto.Marshal(m)
+}
+
+func ProtoEncoder() Encoder {
+	return &protoEncoder{}
+}
+
 func ByteEncoder() Encoder {
 	return &byteEncoder{}
 }
@@ -0,0 +1,256 @@
+package kafka
+
+import (
+	"context"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"microservice/app/core"
+	"microservice/app/tracing"
+)
+
+// Handler processes message of topic. Offset is saved after handler was called,
+// with postgres offset store ctx contains transaction which is shared with the offset.
+// Failed handler is retried by kafka.retry policy, then message is sent to <topic>.dlq.
+type Handler[T any] func(ctx context.Context, msg *Message[T]) error
+
+// Subscribe consumes all partitions of topic in consumer group kafka.group.
+// Partitions are rebalanced between instances of service, offsets are committed to kafka
+// and to offset store of topic. Consuming is stopped when ctx is done or by Close.
+func (t *KafkaTopic[T]) Subscribe(ctx context.Context, handler Handler[T]) error {
+
+	if k.group == "" {
+		return errors.New("kafka.group is required for subscribe")
+	}
+
+	offsets, err := t.offsetStore()
+	if err != nil {
+		return err
+	}
+
+	retry, err := t.retryPolicy()
+	if err != nil {
+		return err
+	}
+
+	group, err := sarama.NewConsumerGroup(k.brokers, k.group, k.config)
+	if err != nil {
+		return errors.Wrapf(err, "cannot create consumer group %s for topic %s", k.group, t.topic)
+	}
+
+	k.groupsMx.Lock()
+	k.groups = append(k.groups, group)
+	k.groupsMx.Unlock()
+
+	go func() {
+		for err := range group.Errors() {
+			logger.ErrorWrap(err, "KAFKA: consumer group error in topic %s", t.topic)
+		}
+	}()
+
+	go func() {
+		defer group.Close()
+
+		h := &groupHandler[T]{
+			topic:   t,
+			offsets: offsets,
+			retry:   retry,
+			handler: handler,
+			grouped: true,
+		}
+
+		logger.Info("KAFKA: subscribing: Topic <%s>, Group <%s>", t.topic, k.group)
+		for {
+			// Consume returns on each rebalance, so it is called in loop
+			err := group.Consume(ctx, []string{t.topic}, h)
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) || ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				logger.ErrorWrap(err, "KAFKA: cannot consume topic %s", t.topic)
+				time.Sleep(time.Second)
+			}
+		}
+	}()
+
+	return nil
+}
+
+type groupHandler[T any] struct {
+	topic   *KafkaTopic[T]
+	offsets OffsetStore
+	retry   RetryPolicy
+	handler Handler[T]
+	grouped bool // false for StartPolling, offsets are not marked in group session
+}
+
+// native is true when offsets are kept by consumer group itself
+func (h *groupHandler[T]) native() bool {
+	_, ok := h.offsets.(*kafkaOffsetStore)
+	return ok && h.grouped
+}
+
+func (h *groupHandler[T]) Setup(session sarama.ConsumerGroupSession) error {
+	partitions := session.Claims()[h.topic.topic]
+	logger.Info("KAFKA: partitions assigned: Topic <%s>, Partitions %v", h.topic.topic, partitions)
+
+	if h.native() {
+		return nil
+	}
+
+	// Offset store is the source of truth, group starts from its offsets
+	for _, partition := range partitions {
+		offset, err := h.offsets.Load(session.Context(), h.topic.topic, partition)
+		if err != nil {
+			return errors.Wrapf(err, "cannot load offset of partition %d", partition)
+		}
+		if offset != nil {
+			session.ResetOffset(h.topic.topic, partition, *offset, "")
+		}
+	}
+	return nil
+}
+
+func (h *groupHandler[T]) Cleanup(session sarama.ConsumerGroupSession) error {
+	logger.Info("KAFKA: partitions revoked: Topic <%s>, Partitions %v", h.topic.topic, session.Claims()[h.topic.topic])
+	return nil
+}
+
+// poll handles messages of partition read by StartPolling like ConsumeClaim
+func (h *groupHandler[T]) poll(ctx context.Context, pc sarama.PartitionConsumer) {
+	for {
+		select {
+		case message, ok := <-pc.Messages():
+			if !ok {
+				return
+			}
+
+			st := partitionStat(h.topic.topic, message.Partition)
+			st.received(message.Offset, pc.HighWaterMarkOffset())
+			started := time.Now()
+
+			if err := h.process(ctx, st, message); err != nil {
+				return
+			}
+			st.handled(message.Offset+1, time.Since(started))
+
+		case err, ok := <-pc.Errors():
+			if ok {
+				logger.ErrorWrap(err, "KAFKA: cannot poll topic %s", h.topic.topic)
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (h *groupHandler[T]) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case message, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+
+			st := partitionStat(h.topic.topic, message.Partition)
+			st.received(message.Offset, claim.HighWaterMarkOffset())
+			started := time.Now()
+
+			err := h.process(session.Context(), st, message)
+			if err != nil {
+				// Partitions are revoked while retrying, message will be consumed again
+				return nil
+			}
+			session.MarkMessage(message, "")
+			st.handled(message.Offset+1, time.Since(started))
+
+		// Partitions are revoked
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+// process calls handler with retries, then sends message to dead letters.
+// Error is returned only when ctx is done.
+func (h *groupHandler[T]) process(ctx context.Context, st *partitionStats, message *sarama.ConsumerMessage) error {
+
+	msg := &Message[T]{
+		Details: message,
+	}
+	err := h.topic.encode(message, &msg.Value)
+	if err != nil {
+		// Incorrect format cannot be fixed by retry
+		st.decodeFailed()
+		logger.ErrorWrap(err, "cannot encode kafka message to receiver type %s", traceString(message))
+		return h.deadLetter(ctx, message, err, 0)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = h.handle(ctx, msg)
+		if err == nil {
+			return nil
+		}
+		logger.ErrorWrap(err, "KAFKA: handler failed in topic %s (partition=%d, offset=%d, attempt %d/%d) %s",
+			h.topic.topic, message.Partition, message.Offset, attempt, h.retry.Attempts, traceString(message))
+		if attempt >= h.retry.Attempts {
+			return h.deadLetter(ctx, message, err, attempt)
+		}
+
+		select {
+		case <-time.After(h.retry.Backoff(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (h *groupHandler[T]) handle(ctx context.Context, msg *Message[T]) error {
+
+	ctx = core.WithMethod(msg.Context(ctx), "kafka:"+h.topic.topic)
+	ctx, span := h.topic.startSpan(ctx, trace.SpanKindConsumer,
+		attribute.Int("messaging.kafka.partition", int(msg.Details.Partition)),
+		attribute.Int64("messaging.kafka.message.offset", msg.Details.Offset),
+	)
+	run := func(ctx context.Context) error {
+		err := h.handler(ctx, msg)
+		if err != nil {
+			return err
+		}
+		return h.save(ctx, msg.Details)
+	}
+
+	var err error
+	if tx, ok := h.offsets.(TxOffsetStore); ok {
+		err = tx.Do(ctx, run)
+	} else {
+		err = run(ctx)
+	}
+	tracing.End(span, err)
+	return err
+}
+
+func (h *groupHandler[T]) deadLetter(ctx context.Context, message *sarama.ConsumerMessage, cause error, attempts int) error {
+	err := sendToDLQUntilDone(ctx, h.retry, message, cause, attempts)
+	if err != nil {
+		return err
+	}
+
+	// Message is skipped, so its offset is saved without handler writes
+	err = h.save(ctx, message)
+	if err != nil {
+		logger.ErrorWrap(err, "cannot commit offset after dead-lettered message in topic %s", h.topic.topic)
+	}
+	return nil
+}
+
+func (h *groupHandler[T]) save(ctx context.Context, message *sarama.ConsumerMessage) error {
+	if h.native() {
+		return nil // MarkMessage commits it
+	}
+	return h.offsets.Save(ctx, h.topic.topic, message.Partition, message.Offset+1)
+}
@@ -1,21 +1,36 @@
 package kafka
 
 import (
+	"context"
+	"sync"
+
 	"github.com/Shopify/sarama"
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
 	"microservice/app"
 	"microservice/app/core"
-	"path"
+	"microservice/app/tracing"
 )
 
 var k *KafkaService
 var logger core.Logger
 
 type KafkaService struct {
-	producer      sarama.SyncProducer
-	consumer      sarama.Consumer
-	consumerGroup sarama.ConsumerGroup
+	brokers  []string
+	group    string
+	config   *sarama.Config
+	client   sarama.Client
+	producer sarama.SyncProducer
+	consumer sarama.Consumer
+
+	asyncConfig *sarama.Config
+	async       asyncProducer
+
+	groupsMx sync.Mutex
+	groups   []sarama.ConsumerGroup
+	pollers  []sarama.PartitionConsumer
 }
 
 func InitKafka(l core.Logger) error {
@@ -28,43 +43,98 @@ func InitKafka(l core.Logger) error {
 	}
 
 	config := sarama.NewConfig()
-	config.Producer.Partitioner = sarama.NewRandomPartitioner
+	// Messages with the same key go to the same partition, so their order is kept
+	config.Producer.Partitioner = sarama.NewHashPartitioner
 	config.Producer.RequiredAcks = sarama.WaitForAll
 	config.Producer.Return.Successes = true
 	config.Producer.Return.Errors = true
 	config.Consumer.Offsets.AutoCommit.Enable = true
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	config.Consumer.Return.Errors = true
+
+	err := applySecurityConfig(config)
+	if err != nil {
+		return err
+	}
+	if err := config.Validate(); err != nil {
+		return errors.Wrap(err, "incorrect kafka config")
+	}
+
+	asyncConfig, err := applyProducerConfig(config)
+	if err != nil {
+		return err
+	}
 
 	brokers := viper.GetStringSlice("kafka.brokers")
+	if len(brokers) == 0 {
+		return errors.New("kafka.brokers is required when kafka is enabled")
+	}
 
-	producer, err := sarama.NewSyncProducer(brokers, config)
+	// Topics must exist before consumers and producers use them
+	err = provisionTopics(brokers, config)
 	if err != nil {
 		return err
 	}
 
-	group := viper.GetString("kafka.group")
-	consumerGroup, err := sarama.NewConsumerGroup(brokers, group, config)
+	client, err := sarama.NewClient(brokers, config)
+	if err != nil {
+		return errors.Wrapf(err, "cannot connect to kafka brokers %v", brokers)
+	}
+
+	producer, err := sarama.NewSyncProducerFromClient(client)
 	if err != nil {
 		return err
 	}
 
-	consumer, err := sarama.NewConsumer(brokers, config)
+	consumer, err := sarama.NewConsumerFromClient(client)
 	if err != nil {
 		return err
 	}
 
 	k = &KafkaService{
-		producer:      producer,
-		consumer:      consumer,
-		consumerGroup: consumerGroup,
+		brokers:  brokers,
+		group:    viper.GetString("kafka.group"),
+		config:   config,
+		client:   client,
+		producer: producer,
+		consumer: consumer,
+
+		asyncConfig: asyncConfig,
 	}
 
+	app.AddReadinessCheck("kafka", checkBrokers)
+
 	return nil
 }
 
+// checkBrokers refreshes metadata, it fails when no broker is reachable
+func checkBrokers(ctx context.Context) error {
+	if k.client.Closed() {
+		return errors.New("kafka client is closed")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- k.client.RefreshMetadata()
+	}()
+	select {
+	case err := <-done:
+		return errors.Wrap(err, "kafka brokers are not reachable")
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "kafka brokers are not reachable")
+	}
+}
+
+// Enabled is false when kafka is turned off in config, topics cannot be used then
+func Enabled() bool {
+	return k != nil
+}
+
 type KafkaTopic[T any] struct {
-	topic        string
-	encoder      Encoder
-	topicStorage *app.Storage
+	topic   string
+	encoder Encoder
+	offsets OffsetStore
+	retry   *RetryPolicy
 }
 
 func Topics() ([]string, error) {
@@ -81,116 +151,162 @@ func Topic[T any](topic string, encoder ...Encoder) (*KafkaTopic[T], error) {
 	case *[]byte:
 		enc = ByteEncoder()
 	default:
-		enc = JsonEncoder()
+		var zero T
+		if _, ok := any(zero).(proto.Message); ok {
+			enc = ProtoEncoder()
+		} else {
+			enc = JsonEncoder()
+		}
 	}
 	if len(encoder) != 0 {
 		enc = encoder[0]
 	}
 
-	// Storage for offsets
-	storage, err := app.NewStorage(path.Join("offsets", topic))
-	if err != nil {
-		return nil, errors.Wrapf(err, "cannot create storage for kafka topic %s", topic)
-	}
-
 	return &KafkaTopic[T]{
-		topic:        topic,
-		encoder:      enc,
-		topicStorage: storage,
+		topic:   topic,
+		encoder: enc,
 	}, nil
 }
 
-func (t *KafkaTopic[T]) Produce(obj T) error {
+// WithOffsetStore overrides store from kafka.offsets config, call it before consuming
+func (t *KafkaTopic[T]) WithOffsetStore(store OffsetStore) *KafkaTopic[T] {
+	t.offsets = store
+	return t
+}
+
+// offsetStore is created on first use, so topics which only produce don't need it
+func (t *KafkaTopic[T]) offsetStore() (OffsetStore, error) {
+	if t.offsets == nil {
+		store, err := newOffsetStore(t.topic)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot create offset store for topic %s", t.topic)
+		}
+		t.offsets = store
+	}
+	return t.offsets, nil
+}
+
+// WithRetry overrides policy from kafka.retry config
+func (t *KafkaTopic[T]) WithRetry(policy RetryPolicy) *KafkaTopic[T] {
+	policy = policy.withDefaults()
+	t.retry = &policy
+	return t
+}
 
+func (t *KafkaTopic[T]) retryPolicy() (RetryPolicy, error) {
+	if t.retry == nil {
+		policy, err := retryPolicy(t.topic)
+		if err != nil {
+			return policy, err
+		}
+		t.retry = &policy
+	}
+	return *t.retry, nil
+}
+
+// decode obj to message value, header encoders add their headers
+func (t *KafkaTopic[T]) decode(obj T) ([]byte, []sarama.RecordHeader, error) {
+	if enc, ok := t.encoder.(HeaderEncoder); ok {
+		return enc.DecodeWithHeaders(obj)
+	}
 	msg, err := t.encoder.Decode(obj)
+	return msg, nil, err
+}
+
+// encode message to out
+func (t *KafkaTopic[T]) encode(message *sarama.ConsumerMessage, out *T) error {
+	if enc, ok := t.encoder.(HeaderEncoder); ok {
+		return enc.EncodeWithHeaders(message.Value, message.Headers, out)
+	}
+	return t.encoder.Encode(message.Value, out)
+}
+
+func (t *KafkaTopic[T]) Produce(obj T) error {
+	return t.ProduceWithKey(context.Background(), "", obj)
+}
+
+// ProduceWithKey sends obj with message key (empty key is not set) and headers, trace of ctx is added to headers
+func (t *KafkaTopic[T]) ProduceWithKey(ctx context.Context, key string, obj T, headers ...sarama.RecordHeader) error {
+
+	ctx, span := t.startSpan(ctx, trace.SpanKindProducer)
+	err := t.produce(ctx, key, obj, headers)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *KafkaTopic[T]) produce(ctx context.Context, key string, obj T, headers []sarama.RecordHeader) error {
+
+	msg, extra, err := t.decode(obj)
 	if err != nil {
 		return err
 	}
+	headers = withTraceHeaders(ctx, append(headers, extra...))
 
-	partition := int32(0) // Partition = 0!
 	message := &sarama.ProducerMessage{
-		Topic:     t.topic,
-		Partition: partition,
-		Value:     sarama.ByteEncoder(msg),
+		Topic:   t.topic,
+		Value:   sarama.ByteEncoder(msg),
+		Headers: headers,
+	}
+	if key != "" {
+		message.Key = sarama.StringEncoder(key)
 	}
 
-	_, offset, err := k.producer.SendMessage(message)
+	partition, offset, err := k.producer.SendMessage(message)
 	if err != nil {
 		return err
 	}
-	logger.Debug("Kafka message sent to topic %s (offset=%d)", t.topic, offset)
+	logger.Debug("Kafka message sent to topic %s (partition=%d, offset=%d)", t.topic, partition, offset)
 	return nil
 }
 
-func (t *KafkaTopic[T]) StartPolling() (chan *Message[T], error) {
-
-	//partitionList, err := k.consumer.Partitions(t.topic)
-	//if err != nil {
-	//	return nil, err
-	//}
+// StartPolling reads all partitions of topic without consumer group, offsets are kept in offset store of topic.
+// Use Subscribe to share partitions between instances of service. Failed handler is retried by kafka.retry policy,
+// then message is sent to <topic>.dlq. Polling is stopped when ctx is done or by Close.
+func (t *KafkaTopic[T]) StartPolling(ctx context.Context, handler Handler[T]) error {
 
-	// Get last offset from storage
-	initialOffset, err := t.topicStorage.GetInt64(t.topic)
+	offsets, err := t.offsetStore()
 	if err != nil {
-		return nil, errors.Wrapf(err, "cannot get initial offset of topic %s in storage", t.topic)
-	}
-	if initialOffset == nil {
-		x := sarama.OffsetOldest
-		initialOffset = &x
+		return err
 	}
 
-	messages := make(chan *Message[T], 1)
-	partition := int32(0) // Partition 0!
-	pc, err := k.consumer.ConsumePartition(t.topic, partition, *initialOffset)
+	retry, err := t.retryPolicy()
 	if err != nil {
-		return nil, errors.Wrapf(err, "cannot consume broker partition %d", partition)
-	}
-
-	go func(pc sarama.PartitionConsumer) {
-		logger.Info("KAFKA: starting polling messages: Topic <%s>, Offset: <%d>", t.topic, *initialOffset)
-		for message := range pc.Messages() {
-			msg := &Message[T]{
-				Details: message,
-			}
-			err := t.encoder.Encode(message.Value, &msg.Value)
-			if err != nil {
-				//e have incorrect format - skip it
-				logger.ErrorWrap(err, "cannot encode kafka message to receiver type")
-				err := t.CommitOffset(msg)
-				if err != nil {
-					logger.ErrorWrap(err, "cannot commit offset after failed encoding in topic %s", t.topic)
-				}
-				continue
-			}
-			messages <- msg
-		}
-	}(pc)
-
-	return messages, nil
-}
+		return err
+	}
 
-func (t *KafkaTopic[T]) CommitOffset(msg *Message[T]) error {
-	lastOffset, err := t.topicStorage.GetInt64(t.topic)
+	partitionList, err := k.consumer.Partitions(t.topic)
 	if err != nil {
-		return errors.Wrapf(err, "cannot get offset of topic %s in storage", t.topic)
-	}
-	if lastOffset == nil {
-		firstOffset := int64(0)
-		lastOffset = &firstOffset
+		return errors.Wrapf(err, "cannot get partitions of topic %s", t.topic)
 	}
-	newOffset := *lastOffset + 1
 
-	// UserAll last offsets before msg.Offset should be handled
-	// If not then we have problems!
-	if newOffset != msg.Details.Offset+1 {
-		logger.Error("newOffset != msg.Offset+1 in topic %s", t.topic)
+	h := &groupHandler[T]{
+		topic:   t,
+		offsets: offsets,
+		retry:   retry,
+		handler: handler,
 	}
+	for _, partition := range partitionList {
 
-	// Anyway we commit with message offset+1
-	committedOffset := msg.Details.Offset + 1
-	err = t.topicStorage.PutInt64(t.topic, committedOffset)
-	if err != nil {
-		return errors.Wrapf(err, "cannot put new offset %d of topic %s in storage", committedOffset, t.topic)
+		// Get last offset from storage
+		initialOffset, err := offsets.Load(ctx, t.topic, partition)
+		if err != nil {
+			return errors.Wrapf(err, "cannot get initial offset of topic %s in storage", t.topic)
+		}
+		if initialOffset == nil {
+			x := sarama.OffsetOldest
+			initialOffset = &x
+		}
+
+		pc, err := k.consumer.ConsumePartition(t.topic, partition, *initialOffset)
+		if err != nil {
+			return errors.Wrapf(err, "cannot consume broker partition %d", partition)
+		}
+		k.groupsMx.Lock()
+		k.pollers = append(k.pollers, pc)
+		k.groupsMx.Unlock()
+
+		logger.Info("KAFKA: starting polling messages: Topic <%s>, Partition <%d>, Offset: <%d>", t.topic, partition, *initialOffset)
+		go h.poll(ctx, pc)
 	}
 
 	return nil
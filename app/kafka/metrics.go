@@ -0,0 +1,66 @@
+package kafka
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"microservice/app/metrics"
+)
+
+func init() {
+	metrics.Registry.MustRegister(newStatsCollector())
+}
+
+// statsCollector exports Stats on scrape, metrics package cannot import kafka
+type statsCollector struct {
+	lag            *prometheus.Desc
+	hwm            *prometheus.Desc
+	committed      *prometheus.Desc
+	consumed       *prometheus.Desc
+	decodeFailures *prometheus.Desc
+	rate           *prometheus.Desc
+	latencyAvg     *prometheus.Desc
+	latencyMax     *prometheus.Desc
+}
+
+func newStatsCollector() *statsCollector {
+	labels := []string{"topic", "partition"}
+	return &statsCollector{
+		lag:            prometheus.NewDesc("kafka_consumer_lag", "Messages of partition which are not handled yet", labels, nil),
+		hwm:            prometheus.NewDesc("kafka_high_water_mark", "Offset of the next message which will be written to partition", labels, nil),
+		committed:      prometheus.NewDesc("kafka_committed_offset", "Offset of the next message to handle, -1 if nothing was committed yet", labels, nil),
+		consumed:       prometheus.NewDesc("kafka_messages_consumed_total", "Messages read from partition", labels, nil),
+		decodeFailures: prometheus.NewDesc("kafka_decode_failures_total", "Messages which cannot be decoded", labels, nil),
+		rate:           prometheus.NewDesc("kafka_messages_per_second", "Messages read per second in the last minute", labels, nil),
+		latencyAvg:     prometheus.NewDesc("kafka_handler_latency_avg_seconds", "Average handler latency in the last minute", labels, nil),
+		latencyMax:     prometheus.NewDesc("kafka_handler_latency_max_seconds", "Max handler latency in the last minute", labels, nil),
+	}
+}
+
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.lag
+	ch <- c.hwm
+	ch <- c.committed
+	ch <- c.consumed
+	ch <- c.decodeFailures
+	ch <- c.rate
+	ch <- c.latencyAvg
+	ch <- c.latencyMax
+}
+
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, st := range Stats() {
+		partition := strconv.Itoa(int(st.Partition))
+		gauge := func(desc *prometheus.Desc, value float64) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, st.Topic, partition)
+		}
+		gauge(c.lag, float64(st.Lag))
+		gauge(c.hwm, float64(st.HighWaterMark))
+		gauge(c.committed, float64(st.CommittedOffset))
+		gauge(c.rate, st.MessagesPerSecond)
+		gauge(c.latencyAvg, st.HandlerLatencyAvg.Seconds())
+		gauge(c.latencyMax, st.HandlerLatencyMax.Seconds())
+		ch <- prometheus.MustNewConstMetric(c.consumed, prometheus.CounterValue, float64(st.Consumed), st.Topic, partition)
+		ch <- prometheus.MustNewConstMetric(c.decodeFailures, prometheus.CounterValue, float64(st.DecodeFailures), st.Topic, partition)
+	}
+}
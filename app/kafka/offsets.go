@@ -0,0 +1,241 @@
+package kafka
+
+import (
+	"context"
+	"database/sql"
+	"microservice/app"
+	"microservice/app/core"
+	"path"
+	"strconv"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	trmsql "github.com/avito-tech/go-transaction-manager/sql"
+	"github.com/avito-tech/go-transaction-manager/trm/manager"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+const (
+	BitcaskOffsets  = "bitcask"
+	PostgresOffsets = "postgres"
+	KafkaOffsets    = "kafka"
+)
+
+// OffsetStore keeps offset of the next message to read for each partition of topic
+type OffsetStore interface {
+	// Load returns nil if nothing was saved yet
+	Load(ctx context.Context, topic string, partition int32) (*int64, error)
+	Save(ctx context.Context, topic string, partition int32, offset int64) error
+}
+
+// TxOffsetStore saves offset in the same transaction as writes of handler
+type TxOffsetStore interface {
+	OffsetStore
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// OffsetStoreConfig selects store for topic in kafka.offsets.topics
+type OffsetStoreConfig struct {
+	Topic string `mapstructure:"topic"`
+	Store string `mapstructure:"store"`
+}
+
+// newOffsetStore creates store for topic by kafka.offsets config
+func newOffsetStore(topic string) (OffsetStore, error) {
+
+	kind := viper.GetString("kafka.offsets.store")
+
+	var topics []OffsetStoreConfig
+	if err := viper.UnmarshalKey("kafka.offsets.topics", &topics); err != nil {
+		return nil, errors.Wrap(err, "cannot parse kafka.offsets.topics")
+	}
+	for _, item := range topics {
+		if item.Topic == topic {
+			kind = item.Store
+		}
+	}
+
+	switch kind {
+	case "", BitcaskOffsets:
+		return NewBitcaskOffsetStore(topic)
+	case PostgresOffsets:
+		var store OffsetStore
+		err := core.GetDI().Invoke(func(db *sql.DB, getter *trmsql.CtxGetter, tr *manager.Manager) error {
+			// Database is provided as nil if db.enabled is false
+			if db == nil {
+				return errors.Errorf("offset store %s of topic %s requires db.enabled", kind, topic)
+			}
+			store = NewPostgresOffsetStore(db, getter, tr)
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot resolve database for offsets")
+		}
+		return store, nil
+	case KafkaOffsets:
+		return NewKafkaOffsetStore()
+	default:
+		return nil, errors.Errorf("unknown offset store %s for topic %s", kind, topic)
+	}
+}
+
+// Bitcask
+
+type bitcaskOffsetStore struct {
+	storage *app.Storage
+}
+
+// NewBitcaskOffsetStore keeps offsets in local file storage/offsets/<topic>
+func NewBitcaskOffsetStore(topic string) (OffsetStore, error) {
+	storage, err := app.NewStorage(path.Join("offsets", topic))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create storage for kafka topic %s", topic)
+	}
+	return &bitcaskOffsetStore{storage: storage}, nil
+}
+
+// key in storage, partition 0 uses topic name as before partitions support
+func (s *bitcaskOffsetStore) key(topic string, partition int32) string {
+	if partition == 0 {
+		return topic
+	}
+	return topic + "/" + strconv.Itoa(int(partition))
+}
+
+func (s *bitcaskOffsetStore) Load(_ context.Context, topic string, partition int32) (*int64, error) {
+	return s.storage.GetInt64(s.key(topic, partition))
+}
+
+func (s *bitcaskOffsetStore) Save(_ context.Context, topic string, partition int32, offset int64) error {
+	return s.storage.PutInt64(s.key(topic, partition), offset)
+}
+
+func (s *bitcaskOffsetStore) Close() error {
+	return s.storage.Close()
+}
+
+// Postgres
+
+type postgresOffsetStore struct {
+	db       *sql.DB
+	getter   *trmsql.CtxGetter
+	tr       *manager.Manager
+	consumer string
+}
+
+// NewPostgresOffsetStore keeps offsets in kafka_offsets table, in transaction from ctx if any
+func NewPostgresOffsetStore(db *sql.DB, getter *trmsql.CtxGetter, tr *manager.Manager) TxOffsetStore {
+	consumer := viper.GetString("kafka.group")
+	if consumer == "" {
+		consumer = "default"
+	}
+	return &postgresOffsetStore{
+		db:       db,
+		getter:   getter,
+		tr:       tr,
+		consumer: consumer,
+	}
+}
+
+func (s *postgresOffsetStore) Load(ctx context.Context, topic string, partition int32) (*int64, error) {
+	query := `SELECT next_offset FROM kafka_offsets WHERE consumer = $1 AND topic = $2 AND partition_id = $3`
+
+	var offset int64
+	err := s.getter.DefaultTrOrDB(ctx, s.db).QueryRowContext(ctx, query, s.consumer, topic, partition).Scan(&offset)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot load offset of topic %s partition %d", topic, partition)
+	}
+	return &offset, nil
+}
+
+func (s *postgresOffsetStore) Save(ctx context.Context, topic string, partition int32, offset int64) error {
+	query := `INSERT INTO kafka_offsets (consumer, topic, partition_id, next_offset) VALUES ($1, $2, $3, $4)
+			  ON CONFLICT (consumer, topic, partition_id) DO UPDATE SET next_offset = $4, updated_at = now()`
+
+	_, err := s.getter.DefaultTrOrDB(ctx, s.db).ExecContext(ctx, query, s.consumer, topic, partition, offset)
+	if err != nil {
+		return errors.Wrapf(err, "cannot save offset of topic %s partition %d", topic, partition)
+	}
+	return nil
+}
+
+func (s *postgresOffsetStore) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return s.tr.Do(ctx, fn)
+}
+
+// Kafka
+
+type kafkaOffsetStore struct {
+	manager sarama.OffsetManager
+
+	mx         sync.Mutex
+	partitions map[string]sarama.PartitionOffsetManager
+}
+
+// NewKafkaOffsetStore commits offsets to kafka for consumer group kafka.group
+func NewKafkaOffsetStore() (OffsetStore, error) {
+	if k.group == "" {
+		return nil, errors.New("kafka.group is required for kafka offsets")
+	}
+	om, err := sarama.NewOffsetManagerFromClient(k.group, k.client)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create kafka offset manager")
+	}
+	return &kafkaOffsetStore{
+		manager:    om,
+		partitions: make(map[string]sarama.PartitionOffsetManager),
+	}, nil
+}
+
+func (s *kafkaOffsetStore) partition(topic string, partition int32) (sarama.PartitionOffsetManager, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	key := topic + "/" + strconv.Itoa(int(partition))
+	if pom, ok := s.partitions[key]; ok {
+		return pom, nil
+	}
+	pom, err := s.manager.ManagePartition(topic, partition)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot manage offsets of topic %s partition %d", topic, partition)
+	}
+	s.partitions[key] = pom
+	return pom, nil
+}
+
+func (s *kafkaOffsetStore) Load(_ context.Context, topic string, partition int32) (*int64, error) {
+	pom, err := s.partition(topic, partition)
+	if err != nil {
+		return nil, err
+	}
+	offset, _ := pom.NextOffset()
+	if offset < 0 {
+		return nil, nil
+	}
+	return &offset, nil
+}
+
+func (s *kafkaOffsetStore) Save(_ context.Context, topic string, partition int32, offset int64) error {
+	pom, err := s.partition(topic, partition)
+	if err != nil {
+		return err
+	}
+	// ResetOffset allows to move offset back too
+	pom.ResetOffset(offset, "")
+	s.manager.Commit()
+	return nil
+}
+
+func (s *kafkaOffsetStore) Close() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	for _, pom := range s.partitions {
+		pom.AsyncClose()
+	}
+	return s.manager.Close()
+}
@@ -0,0 +1,182 @@
+package kafka
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"git.mills.io/prologic/bitcask"
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+)
+
+// Targets of ResetOffsets
+const (
+	ResetEarliest  = "earliest"
+	ResetLatest    = "latest"
+	ResetOffset    = "offset"
+	ResetTimestamp = "timestamp"
+)
+
+// OffsetReset is position of partition before and after reset
+type OffsetReset struct {
+	Partition int32
+	Current   *int64 // nil if nothing was stored
+	Target    int64
+	Oldest    int64
+	Newest    int64
+	// Messages which will be read from target position
+	Messages int64
+}
+
+// ResetRequest of ResetOffsets, Offset is used with ResetOffset and Timestamp with ResetTimestamp
+type ResetRequest struct {
+	Topic     string
+	To        string
+	Offset    int64
+	Timestamp time.Time
+	Partition int32 // -1 - all partitions
+	DryRun    bool
+}
+
+// ResetOffsets moves offsets of topic in its offset store. Consumers of topic must be stopped:
+// bitcask store is locked by running service, consumer group of kafka.group must be empty.
+func ResetOffsets(ctx context.Context, req ResetRequest) ([]OffsetReset, error) {
+
+	switch req.To {
+	case ResetEarliest, ResetLatest, ResetOffset, ResetTimestamp:
+	default:
+		return nil, errors.Errorf("unknown reset target %s", req.To)
+	}
+
+	partitions, err := k.client.Partitions(req.Topic)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get partitions of topic %s", req.Topic)
+	}
+	if req.Partition >= 0 {
+		found := false
+		for _, p := range partitions {
+			found = found || p == req.Partition
+		}
+		if !found {
+			return nil, errors.Errorf("topic %s has no partition %d", req.Topic, req.Partition)
+		}
+		partitions = []int32{req.Partition}
+	}
+
+	if !req.DryRun {
+		if err := checkGroupStopped(); err != nil {
+			return nil, err
+		}
+	}
+
+	store, err := newOffsetStore(req.Topic)
+	if errors.Is(err, bitcask.ErrDatabaseLocked) {
+		return nil, errors.Errorf("offsets of topic %s are locked by running service, stop it first", req.Topic)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open offset store of topic %s", req.Topic)
+	}
+	if closer, ok := store.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	result := make([]OffsetReset, 0, len(partitions))
+	for _, partition := range partitions {
+		reset, err := targetOffset(req, partition)
+		if err != nil {
+			return nil, err
+		}
+		reset.Current, err = store.Load(ctx, req.Topic, partition)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot load offset of partition %d", partition)
+		}
+		result = append(result, *reset)
+	}
+
+	if req.DryRun {
+		return result, nil
+	}
+
+	for _, reset := range result {
+		err := store.Save(ctx, req.Topic, reset.Partition, reset.Target)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot save offset of partition %d", reset.Partition)
+		}
+		logger.Warn("KAFKA: offset of topic %s partition %d was reset to %d", req.Topic, reset.Partition, reset.Target)
+	}
+	return result, nil
+}
+
+func targetOffset(req ResetRequest, partition int32) (*OffsetReset, error) {
+
+	oldest, err := k.client.GetOffset(req.Topic, partition, sarama.OffsetOldest)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get oldest offset of partition %d", partition)
+	}
+	newest, err := k.client.GetOffset(req.Topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get newest offset of partition %d", partition)
+	}
+
+	reset := &OffsetReset{
+		Partition: partition,
+		Oldest:    oldest,
+		Newest:    newest,
+	}
+
+	switch req.To {
+	case ResetEarliest:
+		reset.Target = oldest
+	case ResetLatest:
+		reset.Target = newest
+	case ResetOffset:
+		reset.Target = req.Offset
+	case ResetTimestamp:
+		// First message with timestamp >= requested, -1 if there are no such messages
+		offset, err := k.client.GetOffset(req.Topic, partition, req.Timestamp.UnixMilli())
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get offset by time of partition %d", partition)
+		}
+		reset.Target = offset
+		if offset < 0 {
+			reset.Target = newest
+		}
+	}
+
+	// Offsets outside of log are moved to its bounds
+	if reset.Target < oldest {
+		reset.Target = oldest
+	}
+	if reset.Target > newest {
+		reset.Target = newest
+	}
+	reset.Messages = newest - reset.Target
+	return reset, nil
+}
+
+// checkGroupStopped fails if there are active members in consumer group,
+// otherwise group would overwrite offsets on the next commit
+func checkGroupStopped() error {
+	if k.group == "" {
+		return nil
+	}
+
+	admin, err := sarama.NewClusterAdmin(k.brokers, k.config)
+	if err != nil {
+		return errors.Wrap(err, "cannot create kafka cluster admin")
+	}
+	defer admin.Close()
+
+	groups, err := admin.DescribeConsumerGroups([]string{k.group})
+	if err != nil {
+		return errors.Wrapf(err, "cannot describe consumer group %s", k.group)
+	}
+	for _, group := range groups {
+		if group.State != "Empty" && group.State != "Dead" && group.State != "" {
+			return errors.Errorf("consumer group %s is %s with %d members, stop consumers first",
+				group.GroupId, group.State, len(group.Members))
+		}
+	}
+	return nil
+}
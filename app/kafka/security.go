@@ -0,0 +1,139 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/xdg-go/scram"
+)
+
+// applySecurityConfig sets client id, version, SASL and TLS from kafka config
+func applySecurityConfig(config *sarama.Config) error {
+
+	if clientId := viper.GetString("kafka.client_id"); clientId != "" {
+		config.ClientID = clientId
+	}
+
+	if version := viper.GetString("kafka.version"); version != "" {
+		v, err := sarama.ParseKafkaVersion(version)
+		if err != nil {
+			return errors.Wrapf(err, "incorrect kafka.version %s", version)
+		}
+		config.Version = v
+	}
+
+	if viper.GetBool("kafka.tls.enabled") {
+		tlsConfig, err := kafkaTLSConfig()
+		if err != nil {
+			return err
+		}
+		config.Net.TLS.Enable = true
+		config.Net.TLS.Config = tlsConfig
+	}
+
+	if viper.GetBool("kafka.sasl.enabled") {
+		if err := applySASLConfig(config); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func kafkaTLSConfig() (*tls.Config, error) {
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: viper.GetBool("kafka.tls.insecure_skip_verify"),
+	}
+
+	if ca := viper.GetString("kafka.tls.ca"); ca != "" {
+		pem, err := os.ReadFile(ca)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read kafka.tls.ca")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("kafka.tls.ca %s has no PEM certificates", ca)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	cert, key := viper.GetString("kafka.tls.cert"), viper.GetString("kafka.tls.key")
+	if (cert == "") != (key == "") {
+		return nil, errors.New("both kafka.tls.cert and kafka.tls.key are required for client certificate")
+	}
+	if cert != "" {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot load kafka client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+
+	return tlsConfig, nil
+}
+
+func applySASLConfig(config *sarama.Config) error {
+
+	user, password := viper.GetString("kafka.sasl.user"), viper.GetString("kafka.sasl.password")
+	if user == "" || password == "" {
+		return errors.New("kafka.sasl.user and kafka.sasl.password are required for SASL")
+	}
+	if !config.Net.TLS.Enable {
+		logger.Warn("KAFKA: SASL is used without TLS, password may be sent in plain text")
+	}
+
+	config.Net.SASL.Enable = true
+	config.Net.SASL.Handshake = true
+	config.Net.SASL.User = user
+	config.Net.SASL.Password = password
+
+	mechanism := strings.ToUpper(viper.GetString("kafka.sasl.mechanism"))
+	switch sarama.SASLMechanism(mechanism) {
+	case "", sarama.SASLTypePlaintext:
+		config.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	case sarama.SASLTypeSCRAMSHA256:
+		config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+		config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hash: scram.SHA256}
+		}
+	case sarama.SASLTypeSCRAMSHA512:
+		config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+		config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hash: scram.SHA512}
+		}
+	default:
+		return errors.Errorf("unknown kafka.sasl.mechanism %s, use PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512", mechanism)
+	}
+
+	return nil
+}
+
+// scramClient implements sarama.SCRAMClient
+type scramClient struct {
+	hash         scram.HashGeneratorFcn
+	conversation *scram.ClientConversation
+}
+
+func (c *scramClient) Begin(user, password, authzId string) error {
+	client, err := c.hash.NewClient(user, password, authzId)
+	if err != nil {
+		return errors.Wrap(err, "cannot create SCRAM client")
+	}
+	c.conversation = client.NewConversation()
+	return nil
+}
+
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.conversation.Step(challenge)
+}
+
+func (c *scramClient) Done() bool {
+	return c.conversation.Done()
+}
@@ -0,0 +1,178 @@
+package kafka
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Rate and latency are calculated for the last statsWindow seconds
+const statsWindow = 60
+
+// PartitionStats is snapshot of consuming of topic partition
+type PartitionStats struct {
+	Topic     string
+	Partition int32
+
+	// Offset of the next message to handle, -1 if nothing was committed yet
+	CommittedOffset int64
+	// Offset of the next message which will be written to partition
+	HighWaterMark int64
+	Lag           int64
+
+	Consumed          int64
+	DecodeFailures    int64
+	MessagesPerSecond float64
+
+	// Handler latency in the window, retries are included
+	HandlerLatencyAvg time.Duration
+	HandlerLatencyMax time.Duration
+}
+
+type statsBucket struct {
+	second     int64
+	messages   int64
+	handled    int64
+	latencySum time.Duration
+	latencyMax time.Duration
+}
+
+type partitionStats struct {
+	mx sync.Mutex
+
+	topic     string
+	partition int32
+
+	committed      int64
+	first          int64 // offset of the first read message, lag is counted from it till the first commit
+	hwm            int64
+	consumed       int64
+	decodeFailures int64
+
+	buckets [statsWindow]statsBucket
+}
+
+var statsMx sync.Mutex
+var stats = make(map[string]*partitionStats)
+
+func partitionStat(topic string, partition int32) *partitionStats {
+	statsMx.Lock()
+	defer statsMx.Unlock()
+
+	key := topic + "/" + strconv.Itoa(int(partition))
+	st, ok := stats[key]
+	if !ok {
+		st = &partitionStats{topic: topic, partition: partition, committed: -1, first: -1}
+		stats[key] = st
+	}
+	return st
+}
+
+// bucket of current second, called under lock
+func (s *partitionStats) bucket(now time.Time) *statsBucket {
+	second := now.Unix()
+	b := &s.buckets[second%statsWindow]
+	if b.second != second {
+		*b = statsBucket{second: second}
+	}
+	return b
+}
+
+// received is called when message is read, hwm is high-water mark of partition at the moment
+func (s *partitionStats) received(offset int64, hwm int64) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.first < 0 {
+		s.first = offset
+	}
+	s.hwm = hwm
+	s.consumed++
+	s.bucket(time.Now()).messages++
+}
+
+func (s *partitionStats) decodeFailed() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.decodeFailures++
+}
+
+// handled is called after message was handled, offset is the next offset to read
+func (s *partitionStats) handled(offset int64, latency time.Duration) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.committed = offset
+	b := s.bucket(time.Now())
+	b.handled++
+	b.latencySum += latency
+	if latency > b.latencyMax {
+		b.latencyMax = latency
+	}
+}
+
+func (s *partitionStats) snapshot(now time.Time) PartitionStats {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	result := PartitionStats{
+		Topic:           s.topic,
+		Partition:       s.partition,
+		CommittedOffset: s.committed,
+		HighWaterMark:   s.hwm,
+		Consumed:        s.consumed,
+		DecodeFailures:  s.decodeFailures,
+	}
+	// Before the first commit everything from the first read message is not handled
+	handledTill := s.committed
+	if handledTill < 0 {
+		handledTill = s.first
+	}
+	if handledTill >= 0 && s.hwm > handledTill {
+		result.Lag = s.hwm - handledTill
+	}
+
+	var messages, handled int64
+	var latency time.Duration
+	from := now.Unix() - statsWindow
+	for _, b := range s.buckets {
+		if b.second <= from {
+			continue
+		}
+		messages += b.messages
+		handled += b.handled
+		latency += b.latencySum
+		if b.latencyMax > result.HandlerLatencyMax {
+			result.HandlerLatencyMax = b.latencyMax
+		}
+	}
+	result.MessagesPerSecond = float64(messages) / statsWindow
+	if handled > 0 {
+		result.HandlerLatencyAvg = latency / time.Duration(handled)
+	}
+	return result
+}
+
+// Stats returns consuming stats of all partitions which were read by this instance, sorted by topic and partition
+func Stats() []PartitionStats {
+	statsMx.Lock()
+	list := make([]*partitionStats, 0, len(stats))
+	for _, st := range stats {
+		list = append(list, st)
+	}
+	statsMx.Unlock()
+
+	now := time.Now()
+	result := make([]PartitionStats, 0, len(list))
+	for _, st := range list {
+		result = append(result, st.snapshot(now))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Topic != result[j].Topic {
+			return result[i].Topic < result[j].Topic
+		}
+		return result[i].Partition < result[j].Partition
+	})
+	return result
+}
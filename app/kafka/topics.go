@@ -0,0 +1,140 @@
+package kafka
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// Provisioning modes of kafka.provisioning
+const (
+	ProvisionCreate   = "create"
+	ProvisionValidate = "validate"
+	ProvisionOff      = "off"
+)
+
+// TopicConfig is declaration of topic in kafka.topics
+type TopicConfig struct {
+	Name              string        `mapstructure:"name"`
+	Partitions        int32         `mapstructure:"partitions"`
+	ReplicationFactor int16         `mapstructure:"replication_factor"`
+	Retention         time.Duration `mapstructure:"retention"` // 0 - broker default
+	DLQ               bool          `mapstructure:"dlq"`       // declare <name>.dlq with the same settings
+}
+
+func (c TopicConfig) detail() *sarama.TopicDetail {
+	detail := &sarama.TopicDetail{
+		NumPartitions:     c.Partitions,
+		ReplicationFactor: c.ReplicationFactor,
+	}
+	if c.Retention > 0 {
+		retention := strconv.FormatInt(c.Retention.Milliseconds(), 10)
+		detail.ConfigEntries = map[string]*string{"retention.ms": &retention}
+	}
+	return detail
+}
+
+// provisionTopics creates missing topics from kafka.topics or only checks them in validate mode.
+// Existing topics are never changed, differences with config are logged.
+func provisionTopics(brokers []string, config *sarama.Config) error {
+
+	mode := viper.GetString("kafka.provisioning")
+	if mode == "" {
+		mode = ProvisionCreate
+	}
+	if mode == ProvisionOff {
+		return nil
+	}
+	if mode != ProvisionCreate && mode != ProvisionValidate {
+		return errors.Errorf("unknown kafka.provisioning mode %s", mode)
+	}
+
+	var topics []TopicConfig
+	if err := viper.UnmarshalKey("kafka.topics", &topics); err != nil {
+		return errors.Wrap(err, "cannot parse kafka.topics")
+	}
+	if len(topics) == 0 {
+		return nil
+	}
+
+	declared := make([]TopicConfig, 0, len(topics))
+	for _, topic := range topics {
+		if topic.Name == "" {
+			return errors.New("name is required in kafka.topics")
+		}
+		if topic.Partitions <= 0 {
+			topic.Partitions = 1
+		}
+		if topic.ReplicationFactor <= 0 {
+			topic.ReplicationFactor = 1
+		}
+		declared = append(declared, topic)
+		if topic.DLQ {
+			dlq := topic
+			dlq.Name = DLQTopic(topic.Name)
+			declared = append(declared, dlq)
+		}
+	}
+
+	admin, err := sarama.NewClusterAdmin(brokers, config)
+	if err != nil {
+		return errors.Wrap(err, "cannot create kafka cluster admin")
+	}
+	defer admin.Close()
+
+	existing, err := admin.ListTopics()
+	if err != nil {
+		return errors.Wrap(err, "cannot list kafka topics")
+	}
+
+	var missing []string
+	for _, topic := range declared {
+		detail, ok := existing[topic.Name]
+		if ok {
+			checkTopicDrift(topic, detail)
+			continue
+		}
+
+		if mode == ProvisionValidate {
+			missing = append(missing, topic.Name)
+			continue
+		}
+
+		err := admin.CreateTopic(topic.Name, topic.detail(), false)
+		if err != nil && !errors.Is(err, sarama.ErrTopicAlreadyExists) {
+			return errors.Wrapf(err, "cannot create kafka topic %s", topic.Name)
+		}
+		logger.Info("KAFKA: topic %s was created (partitions=%d, replication=%d)",
+			topic.Name, topic.Partitions, topic.ReplicationFactor)
+	}
+
+	if len(missing) != 0 {
+		return errors.Errorf("kafka topics %v are missing", missing)
+	}
+	return nil
+}
+
+func checkTopicDrift(topic TopicConfig, detail sarama.TopicDetail) {
+	if detail.NumPartitions != topic.Partitions {
+		logger.Warn("KAFKA: topic %s has %d partitions, config declares %d",
+			topic.Name, detail.NumPartitions, topic.Partitions)
+	}
+	if detail.ReplicationFactor != topic.ReplicationFactor {
+		logger.Warn("KAFKA: topic %s has replication factor %d, config declares %d",
+			topic.Name, detail.ReplicationFactor, topic.ReplicationFactor)
+	}
+	if topic.Retention > 0 {
+		expected := strconv.FormatInt(topic.Retention.Milliseconds(), 10)
+		actual := "default"
+		if value, ok := detail.ConfigEntries["retention.ms"]; ok && value != nil {
+			actual = *value
+		}
+		if actual != expected {
+			logger.Warn("KAFKA: topic %s has retention.ms %s, config declares %s",
+				topic.Name, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/Shopify/sarama"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"microservice/app/core"
+	"microservice/app/tracing"
+)
+
+// Headers of trace, the same names are used in gRPC metadata
+const (
+	HeaderRequestId   = "x-request-id"
+	HeaderTraceParent = "traceparent"
+	HeaderTraceState  = "tracestate"
+)
+
+// TraceHeaders returns trace of ctx as headers, e.g. to keep them in outbox
+func TraceHeaders(ctx context.Context) map[string]string {
+	trace, ok := core.TraceFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	headers := make(map[string]string, 3)
+	if trace.RequestId != "" {
+		headers[HeaderRequestId] = trace.RequestId
+	}
+	if trace.TraceParent != "" {
+		headers[HeaderTraceParent] = trace.TraceParent
+	}
+	if trace.TraceState != "" {
+		headers[HeaderTraceState] = trace.TraceState
+	}
+	return headers
+}
+
+// withTraceHeaders adds trace of ctx unless headers already have it
+func withTraceHeaders(ctx context.Context, headers []sarama.RecordHeader) []sarama.RecordHeader {
+	if ctx == nil {
+		return headers
+	}
+	trace := TraceHeaders(ctx)
+	for _, h := range headers {
+		delete(trace, string(h.Key))
+	}
+	for key, value := range trace {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+	}
+	return headers
+}
+
+// traceFromHeaders returns nil if message has no trace
+func traceFromHeaders(headers []*sarama.RecordHeader) *core.Trace {
+	trace := &core.Trace{}
+	for _, h := range headers {
+		if h == nil {
+			continue
+		}
+		switch string(h.Key) {
+		case HeaderRequestId:
+			trace.RequestId = string(h.Value)
+		case HeaderTraceParent:
+			if core.ValidTraceParent(string(h.Value)) {
+				trace.TraceParent = string(h.Value)
+			}
+		case HeaderTraceState:
+			trace.TraceState = string(h.Value)
+		}
+	}
+	if trace.RequestId == "" && trace.TraceParent == "" {
+		return nil
+	}
+	return trace
+}
+
+// Context returns parent with trace of message, handlers should use it for calls caused by message
+func (m *Message[T]) Context(parent context.Context) context.Context {
+	if m.Details == nil {
+		return parent
+	}
+	if trace := traceFromHeaders(m.Details.Headers); trace != nil {
+		return core.WithTrace(parent, trace)
+	}
+	return parent
+}
+
+// traceString of message for logs
+func traceString(message *sarama.ConsumerMessage) string {
+	if trace := traceFromHeaders(message.Headers); trace != nil {
+		return trace.String()
+	}
+	return ""
+}
+
+// startSpan of producing or handling message of topic, parent is trace of ctx
+func (t *KafkaTopic[T]) startSpan(ctx context.Context, kind trace.SpanKind, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	operation := "publish"
+	if kind == trace.SpanKindConsumer {
+		operation = "process"
+	}
+	attrs = append(attrs,
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination", t.topic),
+		attribute.String("messaging.operation", operation),
+	)
+	return tracing.Start(ctx, t.topic+" "+operation, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"github.com/pkg/errors"
 	logrus "github.com/sirupsen/logrus"
@@ -8,14 +9,32 @@ import (
 	"microservice/app/core"
 	"microservice/app/logs_hooks"
 	"os"
+	"os/signal"
 	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
 var (
 	log core.Logger
+
+	fileHooksMx sync.Mutex
+	fileHooks   []fileHook
+	reopenOnHUP sync.Once
 )
 
+// fileHook writes to log file which is rotated by logs.rotation
+type fileHook interface {
+	logrus.Hook
+	Reopen() error
+	Close() error
+}
+
 func InitLogs(rootDir ...string) (core.Logger, error) {
 
 	basePath := "."
@@ -37,12 +56,20 @@ func InitLogs(rootDir ...string) (core.Logger, error) {
 	//})
 
 	logrusLogger := logrus.New()
-	logrusLogger.SetFormatter(&logrus.TextFormatter{
-		ForceColors:      true,
-		DisableTimestamp: true,
-		TimestampFormat:  "2006-01-02 15:04:05",
-	})
-	logrusLogger.SetReportCaller(true)
+	switch format := viper.GetString("logs.format"); format {
+	case "json":
+		logrusLogger.SetFormatter(&logrus.JSONFormatter{
+			TimestampFormat: time.RFC3339Nano,
+		})
+	case "", "text":
+		logrusLogger.SetFormatter(&logrus.TextFormatter{
+			ForceColors:     true,
+			FullTimestamp:   true,
+			TimestampFormat: "15:04:05",
+		})
+	default:
+		return nil, errors.Errorf("unknown logs.format %s", format)
+	}
 	if viper.GetString("app.debug") == "true" {
 		logrusLogger.SetLevel(logrus.TraceLevel)
 	} else {
@@ -50,62 +77,166 @@ func InitLogs(rootDir ...string) (core.Logger, error) {
 	}
 
 	// hooks
-	logrusLogger.AddHook(logs_hooks.NewToFileHook(basePath))
-	logrusLogger.AddHook(logs_hooks.NewToFileErrorHook(basePath))
+	json := viper.GetString("logs.format") == "json"
+	rotation := logs_hooks.Rotation{
+		MaxSize:  viper.GetInt64("logs.rotation.max_size") * 1024 * 1024,
+		Interval: viper.GetDuration("logs.rotation.interval"),
+		MaxFiles: viper.GetInt("logs.rotation.max_files"),
+		Compress: viper.GetBool("logs.rotation.compress"),
+	}
+	allHook, err := logs_hooks.NewToFileHook(basePath, json, rotation)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot init all logs file")
+	}
+	errorHook, err := logs_hooks.NewToFileErrorHook(basePath, json, rotation)
+	if err != nil {
+		_ = allHook.Close()
+		return nil, errors.Wrap(err, "cannot init error logs file")
+	}
+	logrusLogger.AddHook(allHook)
+	logrusLogger.AddHook(errorHook)
+
+	// Files of previous init are not written anymore
+	CloseLogs()
+	fileHooksMx.Lock()
+	fileHooks = []fileHook{allHook, errorHook}
+	fileHooksMx.Unlock()
+
+	reopenOnHUP.Do(reopenLogsOnHUP)
 
 	log = NewDefaultLogger(logrusLogger)
 	return log, nil
 }
 
+// reopenLogsOnHUP reopens log files on SIGHUP, e.g. after they were moved by logrotate
+func reopenLogsOnHUP() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			fileHooksMx.Lock()
+			for _, hook := range fileHooks {
+				if err := hook.Reopen(); err != nil {
+					fmt.Fprintf(os.Stderr, "cannot reopen log file: %s\n", err.Error())
+				}
+			}
+			fileHooksMx.Unlock()
+			if log != nil {
+				log.Info("Log files are reopened")
+			}
+		}
+	}()
+}
+
+// CloseLogs closes log files, call it the last on shutdown
+func CloseLogs() {
+	fileHooksMx.Lock()
+	defer fileHooksMx.Unlock()
+	for _, hook := range fileHooks {
+		if err := hook.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot close log file: %s\n", err.Error())
+		}
+	}
+	fileHooks = nil
+}
+
 type DefaultLogger struct {
 	logger *logrus.Logger
+	fields logrus.Fields
 }
 
 func NewDefaultLogger(logger *logrus.Logger) *DefaultLogger {
 	return &DefaultLogger{logger: logger}
 }
 
+// entry with fields of logger and caller of logger method, logrus would report DefaultLogger itself
+func (l *DefaultLogger) entry() *logrus.Entry {
+	entry := l.logger.WithFields(l.fields)
+	if _, file, line, ok := runtime.Caller(2); ok {
+		entry = entry.WithField("caller", filepath.Base(filepath.Dir(file))+"/"+filepath.Base(file)+":"+strconv.Itoa(line))
+	}
+	return entry
+}
+
 func (l *DefaultLogger) Debug(msg string, args ...interface{}) {
-	l.logger.Debugf(time.Now().Format("15:04:05")+": "+msg, args...)
+	l.entry().Debugf(msg, args...)
 }
 
 func (l *DefaultLogger) Warn(msg string, args ...interface{}) {
-	l.logger.Warnf(time.Now().Format("15:04:05")+": "+msg, args...)
+	l.entry().Warnf(msg, args...)
 }
 
 func (l *DefaultLogger) Info(msg string, args ...interface{}) {
-	l.logger.Infof(time.Now().Format("15:04:05")+": "+msg, args...)
+	l.entry().Infof(msg, args...)
 }
 
 func (l *DefaultLogger) Error(msg string, args ...interface{}) {
-	l.logger.Errorf(time.Now().Format("15:04:05")+": "+msg, args...)
+	l.entry().Errorf(msg, args...)
 }
 
 func (l *DefaultLogger) Fatal(msg string, args ...interface{}) {
-	l.logger.Fatalf(time.Now().Format("15:04:05")+": "+msg, args...)
+	l.entry().Fatalf(msg, args...)
 }
 
 func (l *DefaultLogger) DebugWrap(err error, msg string, args ...interface{}) {
-	msg = fmt.Sprintf(time.Now().Format("15:04:05")+": "+msg, args...)
-	l.logger.Debugf("%s: %s", msg, err.Error())
+	l.entry().Debugf("%s: %s", fmt.Sprintf(msg, args...), err.Error())
 }
 
 func (l *DefaultLogger) WarnWrap(err error, msg string, args ...interface{}) {
-	msg = fmt.Sprintf(time.Now().Format("15:04:05")+": "+msg, args...)
-	l.logger.Warnf("%s: %s", msg, err.Error())
+	l.entry().Warnf("%s: %s", fmt.Sprintf(msg, args...), err.Error())
 }
 
 func (l *DefaultLogger) InfoWrap(err error, msg string, args ...interface{}) {
-	msg = fmt.Sprintf(time.Now().Format("15:04:05")+": "+msg, args...)
-	l.logger.Infof("%s: %s", msg, err.Error())
+	l.entry().Infof("%s: %s", fmt.Sprintf(msg, args...), err.Error())
 }
 
 func (l *DefaultLogger) ErrorWrap(err error, msg string, args ...interface{}) {
-	msg = fmt.Sprintf(time.Now().Format("15:04:05")+": "+msg, args...)
-	l.logger.Errorf("%s: %s", msg, err.Error())
+	l.entry().Errorf("%s: %s", fmt.Sprintf(msg, args...), err.Error())
 }
 
 func (l *DefaultLogger) FatalWrap(err error, msg string, args ...interface{}) {
-	msg = fmt.Sprintf(time.Now().Format("15:04:05")+": "+msg, args...)
-	l.logger.Fatalf("%s: %s", msg, err.Error())
+	l.entry().Fatalf("%s: %s", fmt.Sprintf(msg, args...), err.Error())
+}
+
+func (l *DefaultLogger) With(fields ...interface{}) core.Logger {
+	next := make(logrus.Fields, len(l.fields)+len(fields)/2)
+	for k, v := range l.fields {
+		next[k] = v
+	}
+	for i := 0; i < len(fields); i += 2 {
+		key, ok := fields[i].(string)
+		if !ok {
+			key = fmt.Sprint(fields[i])
+		}
+		if i+1 == len(fields) {
+			// Value without key is kept to not lose it
+			next["!BADKEY"] = fields[i]
+			break
+		}
+		next[key] = fields[i+1]
+	}
+	return &DefaultLogger{logger: l.logger, fields: next}
+}
+
+func (l *DefaultLogger) FromContext(ctx context.Context) core.Logger {
+	var fields []interface{}
+	if trace, ok := core.TraceFromContext(ctx); ok {
+		if trace.RequestId != "" {
+			fields = append(fields, "request_id", trace.RequestId)
+		}
+		// traceparent is 00-<trace id>-<span id>-<flags>
+		if parts := strings.Split(trace.TraceParent, "-"); len(parts) == 4 {
+			fields = append(fields, "trace_id", parts[1], "span_id", parts[2])
+		}
+	}
+	if method, ok := core.MethodFromContext(ctx); ok {
+		fields = append(fields, "method", method)
+	}
+	if identity, ok := core.LoggedIdentity(ctx); ok && identity.UserId >= 0 {
+		fields = append(fields, "user_id", identity.UserId)
+	}
+	if len(fields) == 0 {
+		return l
+	}
+	return l.With(fields...)
 }
@@ -0,0 +1,36 @@
+package logs_hooks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// format of line in log file, fields of text line are key=value after message
+func format(entry *log.Entry, json *log.JSONFormatter) ([]byte, error) {
+	if json != nil {
+		return json.Format(entry)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s](%s) ", strings.ToUpper(entry.Level.String()), entry.Time.Format("2006-01-02 15:04:05"))
+	if caller, ok := entry.Data["caller"]; ok {
+		fmt.Fprintf(&b, "%v ", caller)
+	}
+	b.WriteString(entry.Message)
+
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		if key != "caller" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %s=%v", key, entry.Data[key])
+	}
+	b.WriteString("\n")
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,201 @@
+package logs_hooks
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rotation of log file, zero values turn limits off
+type Rotation struct {
+	MaxSize  int64         // bytes
+	Interval time.Duration // new file at every boundary of interval (UTC), e.g. 24h - at midnight
+	MaxFiles int           // rotated files which are kept
+	Compress bool          // gzip rotated files
+}
+
+// rotatingFile is appended until limit of rotation, then it is renamed to <name>-<time>.log and new file is started
+type rotatingFile struct {
+	mx       sync.Mutex
+	path     string
+	rotation Rotation
+	file     *os.File
+	size     int64
+	opened   time.Time
+
+	// Compression and removing of old files are done in background one by one
+	maintenance sync.Mutex
+}
+
+func openRotatingFile(path string, rotation Rotation) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, rotation: rotation}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open is called under lock
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open log file %s: %w", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("cannot stat log file %s: %w", f.path, err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	// Existing file is rotated by time of the last write
+	f.opened = time.Now()
+	if f.size > 0 {
+		f.opened = info.ModTime()
+	}
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if f.needRotation(len(p), time.Now()) {
+		if err := f.rotate(); err != nil {
+			// Better to keep writing to the old file than to lose logs
+			fmt.Fprintf(os.Stderr, "cannot rotate log file: %s\n", err.Error())
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) needRotation(next int, now time.Time) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.rotation.MaxSize > 0 && f.size+int64(next) > f.rotation.MaxSize {
+		return true
+	}
+	if f.rotation.Interval > 0 && !now.UTC().Truncate(f.rotation.Interval).Equal(f.opened.UTC().Truncate(f.rotation.Interval)) {
+		return true
+	}
+	return false
+}
+
+// rotate is called under lock
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("cannot close log file %s: %w", f.path, err)
+	}
+
+	ext := filepath.Ext(f.path)
+	rotated := strings.TrimSuffix(f.path, ext) + "-" + time.Now().UTC().Format("2006-01-02T15-04-05.000") + ext
+	renameErr := os.Rename(f.path, rotated)
+
+	if err := f.open(); err != nil {
+		f.file = nil
+		return err
+	}
+	if renameErr != nil {
+		return fmt.Errorf("cannot rename log file %s: %w", f.path, renameErr)
+	}
+
+	go f.maintain(rotated)
+	return nil
+}
+
+// maintain compresses rotated file and removes files over rotation.MaxFiles
+func (f *rotatingFile) maintain(rotated string) {
+	f.maintenance.Lock()
+	defer f.maintenance.Unlock()
+
+	if f.rotation.Compress {
+		if err := compressFile(rotated); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot compress log file: %s\n", err.Error())
+		}
+	}
+
+	if f.rotation.MaxFiles <= 0 {
+		return
+	}
+	ext := filepath.Ext(f.path)
+	files, err := filepath.Glob(strings.TrimSuffix(f.path, ext) + "-*" + ext + "*")
+	if err != nil || len(files) <= f.rotation.MaxFiles {
+		return
+	}
+	// Time in name is sortable, the oldest are first
+	sort.Strings(files)
+	for _, file := range files[:len(files)-f.rotation.MaxFiles] {
+		if err := os.Remove(file); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot remove old log file: %s\n", err.Error())
+		}
+	}
+}
+
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Reopen closes file and opens it by path again, e.g. after it was moved by logrotate
+func (f *rotatingFile) Reopen() error {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	if f.file != nil {
+		_ = f.file.Close()
+	}
+	if err := f.open(); err != nil {
+		f.file = nil
+		return err
+	}
+	return nil
+}
+
+func (f *rotatingFile) Close() error {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -3,42 +3,32 @@ package logs_hooks
 import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
-	"os"
-	"strings"
 )
 
-var (
-	all_logs *os.File
-)
+type ToFileAllHook struct {
+	file *rotatingFile
+	json *log.JSONFormatter // nil for text
+}
 
-func initFileAll(rootDir string) {
-	logFile := rootDir + "/logs/all.log"
-	f, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+func NewToFileHook(rootDir string, json bool, rotation Rotation) (*ToFileAllHook, error) {
+	f, err := openRotatingFile(rootDir+"/logs/all.log", rotation)
 	if err != nil {
-		fmt.Println("Failed to create all logfile" + logFile)
-		panic(err)
+		return nil, err
 	}
-	//defer f.Close()
-	all_logs = f
-}
-
-type ToFileAllHook struct{}
-
-func NewToFileHook(rootDir string) *ToFileAllHook {
-	initFileAll(rootDir)
-	return &ToFileAllHook{}
+	if json {
+		return &ToFileAllHook{file: f, json: &log.JSONFormatter{}}, nil
+	}
+	return &ToFileAllHook{file: f}, nil
 }
 
 func (hook *ToFileAllHook) Fire(entry *log.Entry) error {
 
-	msg := fmt.Sprintf("[%s](%s) %s:%d %s\n",
-		strings.ToUpper(entry.Level.String()),
-		entry.Time.Format("2006-01-02 15:04:05"),
-		entry.Caller.File,
-		entry.Caller.Line,
-		entry.Message)
+	msg, err := format(entry, hook.json)
+	if err != nil {
+		return err
+	}
 
-	_, err := all_logs.WriteString(msg)
+	_, err = hook.file.Write(msg)
 	if err != nil {
 		fmt.Printf("Cannot write to all log file. %s", err.Error())
 		return err
@@ -56,3 +46,12 @@ func (hook *ToFileAllHook) Levels() []log.Level {
 		log.DebugLevel,
 	}
 }
+
+// Reopen log file, e.g. on SIGHUP after logrotate
+func (hook *ToFileAllHook) Reopen() error {
+	return hook.file.Reopen()
+}
+
+func (hook *ToFileAllHook) Close() error {
+	return hook.file.Close()
+}
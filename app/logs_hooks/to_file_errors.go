@@ -3,42 +3,32 @@ package logs_hooks
 import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
-	"os"
-	"strings"
 )
 
-var (
-	errors_logs *os.File
-)
+type ToFileErrorHook struct {
+	file *rotatingFile
+	json *log.JSONFormatter // nil for text
+}
 
-func initFile(rootDir string) {
-	logFile := rootDir + "/logs/errors.log"
-	f, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+func NewToFileErrorHook(rootDir string, json bool, rotation Rotation) (*ToFileErrorHook, error) {
+	f, err := openRotatingFile(rootDir+"/logs/errors.log", rotation)
 	if err != nil {
-		fmt.Println("Failed to create error logfile" + logFile)
-		panic(err)
+		return nil, err
 	}
-	//defer f.Close()
-	errors_logs = f
-}
-
-type ToFileErrorHook struct{}
-
-func NewToFileErrorHook(rootDir string) *ToFileErrorHook {
-	initFile(rootDir)
-	return &ToFileErrorHook{}
+	if json {
+		return &ToFileErrorHook{file: f, json: &log.JSONFormatter{}}, nil
+	}
+	return &ToFileErrorHook{file: f}, nil
 }
 
 func (hook *ToFileErrorHook) Fire(entry *log.Entry) error {
 
-	msg := fmt.Sprintf("[%s](%s) %s:%d %s\n",
-		strings.ToUpper(entry.Level.String()),
-		entry.Time.Format("2006-01-02 15:04:05"),
-		entry.Caller.File,
-		entry.Caller.Line,
-		entry.Message)
+	msg, err := format(entry, hook.json)
+	if err != nil {
+		return err
+	}
 
-	_, err := errors_logs.WriteString(msg)
+	_, err = hook.file.Write(msg)
 	if err != nil {
 		fmt.Printf("Cannot write error to log file. %s", err.Error())
 		return err
@@ -53,3 +43,12 @@ func (hook *ToFileErrorHook) Levels() []log.Level {
 		log.ErrorLevel,
 	}
 }
+
+// Reopen log file, e.g. on SIGHUP after logrotate
+func (hook *ToFileErrorHook) Reopen() error {
+	return hook.file.Reopen()
+}
+
+func (hook *ToFileErrorHook) Close() error {
+	return hook.file.Close()
+}
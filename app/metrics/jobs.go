@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	jobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "job_runs_total",
+		Help: "Runs of jobs, result is success or failure",
+	}, []string{"job", "result"})
+
+	jobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "job_run_duration_seconds",
+		Help:    "Duration of job runs",
+		Buckets: []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60, 300},
+	}, []string{"job"})
+
+	jobLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "job_last_success_timestamp_seconds",
+		Help: "Unix time of the last successful run",
+	}, []string{"job"})
+)
+
+// ObserveJob records run of job which took duration
+func ObserveJob(job string, duration time.Duration, err error) {
+	jobDuration.WithLabelValues(job).Observe(duration.Seconds())
+	if err != nil {
+		jobRuns.WithLabelValues(job, "failure").Inc()
+		return
+	}
+	jobRuns.WithLabelValues(job, "success").Inc()
+	jobLastSuccess.WithLabelValues(job).SetToCurrentTime()
+}
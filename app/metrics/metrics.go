@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry of service metrics, own registry keeps metrics of libraries out unless they are registered here
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		rpcRequests,
+		rpcDuration,
+		jobRuns,
+		jobDuration,
+		jobLastSuccess,
+		storages,
+	)
+}
+
+// Handler serves metrics in Prometheus format, e.g. app.HandleHTTP("/metrics", metrics.Handler())
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDB exports sql.DBStats of connection pool as go_sql_* metrics
+func RegisterDB(db *sql.DB, name string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
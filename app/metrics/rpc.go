@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"microservice/app/core"
+)
+
+var (
+	rpcRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rpc_requests_total",
+		Help: "gRPC calls by method, gRPC code and code of Status in response",
+	}, []string{"method", "grpc_code", "status_code"})
+
+	rpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rpc_request_duration_seconds",
+		Help:    "Latency of gRPC calls by method and code of Status in response",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "status_code"})
+)
+
+// UnaryServerInterceptor counts calls. Errors are mostly returned in-band as Status of response,
+// so its code is a label besides gRPC code.
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	start := time.Now()
+	resp, err = handler(ctx, req)
+
+	code := statusCode(resp)
+	rpcDuration.WithLabelValues(info.FullMethod, code).Observe(time.Since(start).Seconds())
+	rpcRequests.WithLabelValues(info.FullMethod, status.Code(err).String(), code).Inc()
+	return resp, err
+}
+
+func statusCode(resp interface{}) string {
+	if code := core.ResponseStatusCode(resp); code != "" {
+		return code
+	}
+	return "none"
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StorageStats returns number of keys and size of files on disk
+type StorageStats func() (keys int, size int64, err error)
+
+var storages = &storageCollector{
+	stats: make(map[string]StorageStats),
+	keys:  prometheus.NewDesc("storage_keys", "Keys in bitcask storage", []string{"storage"}, nil),
+	size:  prometheus.NewDesc("storage_size_bytes", "Size of bitcask storage on disk", []string{"storage"}, nil),
+}
+
+// storageCollector reads stats on scrape, storages can be opened and closed at any time
+type storageCollector struct {
+	mx    sync.RWMutex
+	stats map[string]StorageStats
+	keys  *prometheus.Desc
+	size  *prometheus.Desc
+}
+
+// RegisterStorage adds storage to metrics, storage with the same name is replaced
+func RegisterStorage(name string, stats StorageStats) {
+	storages.mx.Lock()
+	defer storages.mx.Unlock()
+	storages.stats[name] = stats
+}
+
+func UnregisterStorage(name string) {
+	storages.mx.Lock()
+	defer storages.mx.Unlock()
+	delete(storages.stats, name)
+}
+
+func (c *storageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.keys
+	ch <- c.size
+}
+
+func (c *storageCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+
+	for name, stats := range c.stats {
+		keys, size, err := stats()
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(c.size, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.keys, prometheus.GaugeValue, float64(keys), name)
+		ch <- prometheus.MustNewConstMetric(c.size, prometheus.GaugeValue, float64(size), name)
+	}
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"context"
+	"microservice/app/core"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	certIdentities []certIdentity
+)
+
+// CertIdentity maps client certificate (subject, CN or SAN URI) to access role
+type CertIdentity struct {
+	Match string `mapstructure:"match"`
+	Role  string `mapstructure:"role"`
+}
+
+type certIdentity struct {
+	match string
+	role  core.AccessRole
+}
+
+func initCertIdentities() error {
+	var list []CertIdentity
+	if err := viper.UnmarshalKey("app.grpc.identities", &list); err != nil {
+		return errors.Wrap(err, "cannot parse app.grpc.identities")
+	}
+
+	certIdentities = make([]certIdentity, 0, len(list))
+	for _, item := range list {
+		role, err := core.ParseAccessRole(item.Role)
+		if err != nil {
+			return errors.Wrapf(err, "incorrect role of certificate identity %s", item.Match)
+		}
+		certIdentities = append(certIdentities, certIdentity{
+			match: item.Match,
+			role:  role,
+		})
+	}
+	return nil
+}
+
+func peerCertificate(ctx context.Context) (*core.PeerCertificate, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil, false
+	}
+
+	// Only verified certificates are trusted
+	chains := tlsInfo.State.VerifiedChains
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return nil, false
+	}
+	cert := chains[0][0]
+
+	uris := make([]string, 0, len(cert.URIs))
+	for _, uri := range cert.URIs {
+		uris = append(uris, uri.String())
+	}
+
+	return &core.PeerCertificate{
+		Subject:    cert.Subject.String(),
+		CommonName: cert.Subject.CommonName,
+		URIs:       uris,
+	}, true
+}
+
+func matchCertIdentity(cert *core.PeerCertificate) (*certIdentity, bool) {
+	for i, identity := range certIdentities {
+		if identity.match == cert.Subject || identity.match == cert.CommonName {
+			return &certIdentities[i], true
+		}
+		for _, uri := range cert.URIs {
+			if identity.match == uri {
+				return &certIdentities[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Interceptor
+
+func peerIdentity(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+
+	cert, ok := peerCertificate(ctx)
+	if !ok {
+		return handler(ctx, req)
+	}
+	ctx = core.WithPeerCertificate(ctx, cert)
+
+	identity, ok := matchCertIdentity(cert)
+	if !ok {
+		return handler(ctx, req)
+	}
+
+	m, _ := metadata.FromIncomingContext(ctx)
+	userId, err := metadataUserId(m)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "cannot parse user_id: %s", err.Error())
+	}
+
+	ctx = core.WithIdentity(ctx, &core.Identity{
+		UserId:    userId,
+		Role:      identity.role,
+		Principal: "cert:" + identity.match,
+	})
+	return handler(ctx, req)
+}
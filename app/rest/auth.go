@@ -0,0 +1,37 @@
+package rest
+
+import (
+	"microservice/app"
+	"microservice/app/core"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole checks api key from Authorization header (raw or "Bearer <key>") and puts identity into request context
+func RequireRole(log core.Logger, role core.AccessRole) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		log := log.FromContext(ctx.Request.Context())
+		token := strings.TrimPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if token == "" {
+			ctx.AbortWithStatusJSON(401, UnauthorizedError())
+			return
+		}
+
+		identity, err := app.ApiKeyIdentity(token)
+		if err != nil {
+			log.Warn("AUDIT: %s %s denied: %s", ctx.Request.Method, ctx.FullPath(), err.Error())
+			ctx.AbortWithStatusJSON(401, UnauthorizedError())
+			return
+		}
+		if identity.Role < role {
+			log.Warn("AUDIT: %s %s denied for %s", ctx.Request.Method, ctx.FullPath(), identity.Principal)
+			ctx.AbortWithStatusJSON(403, UnauthorizedError())
+			return
+		}
+
+		log.Info("AUDIT: %s %s called by %s", ctx.Request.Method, ctx.FullPath(), identity.Principal)
+		ctx.Request = ctx.Request.WithContext(core.WithIdentity(ctx.Request.Context(), identity))
+		ctx.Next()
+	}
+}
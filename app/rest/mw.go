@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"strconv"
 )
 
 func GeneralMW(ctx *gin.Context) {
@@ -20,6 +21,11 @@ func ErrorMW(ctx *gin.Context) {
 		case *json.UnmarshalTypeError:
 			e := err.(*json.UnmarshalTypeError)
 			ctx.JSON(500, ValidationError(fmt.Sprintf("%s (type) = %s", e.Field, e.Type)))
+		case *json.SyntaxError:
+			ctx.JSON(500, ValidationError("incorrect json"))
+		case *strconv.NumError:
+			e := err.(*strconv.NumError)
+			ctx.JSON(500, ValidationError(fmt.Sprintf("%s is not a number", e.Num)))
 		case validator.ValidationErrors:
 			errs := err.(validator.ValidationErrors)
 			if len(errs) > 0 {
@@ -1,15 +1,21 @@
 package rest
 
 import (
+	"context"
+	"errors"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/spf13/viper"
+	"net/http"
+	"sync"
 	"time"
 )
 
 var (
 	restServer *gin.Engine
+	httpServer *http.Server
+	httpMx     sync.Mutex
 )
 
 func Init() error {
@@ -19,7 +25,7 @@ func Init() error {
 	// CORS
 	restServer.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"POST"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
 		AllowHeaders:     []string{"*"},
 		ExposeHeaders:    []string{},
 		AllowCredentials: true,
@@ -30,8 +36,36 @@ func Init() error {
 	return nil
 }
 
+func Enabled() bool {
+	return viper.GetBool("rest.enabled")
+}
+
 func RunServer() {
 	host := viper.GetString("rest.host")
 	port := viper.GetString("rest.port")
-	restServer.Run(host + ":" + port)
+
+	server := &http.Server{
+		Addr:              host + ":" + port,
+		Handler:           restServer,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	httpMx.Lock()
+	httpServer = server
+	httpMx.Unlock()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		panic(err)
+	}
+}
+
+// Shutdown waits for active requests till ctx is done
+func Shutdown(ctx context.Context) error {
+	httpMx.Lock()
+	server := httpServer
+	httpMx.Unlock()
+
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
 }
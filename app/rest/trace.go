@@ -0,0 +1,54 @@
+package rest
+
+import (
+	"microservice/app/core"
+	"microservice/app/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Headers of trace, the same names are used in gRPC metadata and kafka headers
+const (
+	headerRequestId   = "X-Request-Id"
+	headerTraceParent = "traceparent"
+	headerTraceState  = "tracestate"
+)
+
+// TraceMW takes request id and traceparent from caller or starts new ones, request id is returned to caller.
+// Request is a server span.
+func TraceMW(ctx *gin.Context) {
+	trace := &core.Trace{
+		RequestId:   ctx.GetHeader(headerRequestId),
+		TraceParent: ctx.GetHeader(headerTraceParent),
+		TraceState:  ctx.GetHeader(headerTraceState),
+	}
+	if trace.RequestId == "" || len(trace.RequestId) > 128 {
+		trace.RequestId = core.NewRequestId()
+	}
+	if !core.ValidTraceParent(trace.TraceParent) {
+		trace.TraceParent = ""
+		trace.TraceState = ""
+	}
+
+	ctx.Header(headerRequestId, trace.RequestId)
+	method := ctx.Request.Method + " " + ctx.FullPath()
+	reqCtx := core.WithMethod(core.WithTrace(ctx.Request.Context(), trace), method)
+	reqCtx, span := tracing.Start(reqCtx, method,
+		oteltrace.WithSpanKind(oteltrace.SpanKindServer),
+		oteltrace.WithAttributes(
+			attribute.String("http.method", ctx.Request.Method),
+			attribute.String("http.route", ctx.FullPath()),
+		),
+	)
+	ctx.Request = ctx.Request.WithContext(reqCtx)
+	ctx.Next()
+
+	span.SetAttributes(attribute.Int("http.status_code", ctx.Writer.Status()))
+	var err error
+	if last := ctx.Errors.Last(); last != nil {
+		err = last
+	}
+	tracing.End(span, err)
+}
@@ -4,6 +4,7 @@ import (
 	"git.mills.io/prologic/bitcask"
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
+	"microservice/app/metrics"
 	"os"
 	"path"
 	"strconv"
@@ -34,6 +35,10 @@ func NewStorage(name string) (*Storage, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot create storage")
 	}
+	metrics.RegisterStorage(name, func() (int, int64, error) {
+		stats, err := db.Stats()
+		return stats.Keys, stats.Size, err
+	})
 	return &Storage{
 		db:   db,
 		name: name,
@@ -83,3 +88,12 @@ func (s *Storage) GetInt64(key string) (*int64, error) {
 	}
 	return &r, nil
 }
+
+func (s *Storage) Close() error {
+	metrics.UnregisterStorage(s.name)
+	err := s.db.Close()
+	if err != nil {
+		return errors.Wrapf(err, "cannot close storage %s", s.name)
+	}
+	return nil
+}
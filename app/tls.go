@@ -0,0 +1,167 @@
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+var (
+	serverCerts *certReloader
+)
+
+// certReloader keeps server certificate and client CA pool which are reloaded from disk on change
+type certReloader struct {
+	certFile, keyFile, caFile string
+	clientAuth                tls.ClientAuthType
+	minVersion                uint16
+
+	mx     sync.RWMutex
+	cert   *tls.Certificate
+	caPool *x509.CertPool
+	expiry time.Time
+}
+
+func tlsEnabled() bool {
+	// app.grpc.tsl is kept for old configs
+	return viper.GetBool("app.grpc.tls.enabled") || viper.GetBool("app.grpc.tsl")
+}
+
+func initServerTLS() (*tls.Config, error) {
+
+	clientAuth, err := parseClientAuth(viper.GetString("app.grpc.tls.client_auth"))
+	if err != nil {
+		return nil, err
+	}
+	minVersion, err := parseTLSVersion(viper.GetString("app.grpc.tls.min_version"))
+	if err != nil {
+		return nil, err
+	}
+
+	r := &certReloader{
+		certFile:   viper.GetString("app.grpc.tls.cert"),
+		keyFile:    viper.GetString("app.grpc.tls.key"),
+		caFile:     viper.GetString("app.grpc.tls.ca"),
+		clientAuth: clientAuth,
+		minVersion: minVersion,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	serverCerts = r
+
+	interval := viper.GetDuration("app.grpc.tls.reload_interval")
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	files := []string{r.certFile, r.keyFile}
+	if r.caFile != "" {
+		files = append(files, r.caFile)
+	}
+	watchFiles(interval, func() {
+		err := r.load()
+		if err != nil {
+			log.ErrorWrap(err, "cannot reload TLS certificates, previous are kept")
+		}
+	}, files...)
+
+	return &tls.Config{
+		MinVersion:         minVersion,
+		GetConfigForClient: r.configForClient,
+	}, nil
+}
+
+func (r *certReloader) load() error {
+
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return errors.Wrapf(err, "cannot load certificate %s", r.certFile)
+	}
+
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		return errors.Wrapf(err, "cannot parse certificate %s", r.certFile)
+	}
+
+	var certPool *x509.CertPool
+	if r.caFile != "" {
+		ca, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return errors.Wrapf(err, "cannot read CA %s", r.caFile)
+		}
+		certPool = x509.NewCertPool()
+		if ok := certPool.AppendCertsFromPEM(ca); !ok {
+			return errors.Errorf("failed to append client certs from %s", r.caFile)
+		}
+	} else if r.clientAuth >= tls.VerifyClientCertIfGiven {
+		return errors.New("app.grpc.tls.ca is required to verify client certificates")
+	}
+
+	r.mx.Lock()
+	r.cert = &certificate
+	r.caPool = certPool
+	r.expiry = leaf.NotAfter
+	r.mx.Unlock()
+
+	// Expiry is warned by tls readiness check by app.grpc.tls.expiry_warning
+	log.Info("TLS certificate was loaded: %s, expires at %s", leaf.Subject.String(), leaf.NotAfter.Format(time.RFC3339))
+	return nil
+}
+
+func (r *certReloader) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{*r.cert},
+		ClientCAs:    r.caPool,
+		ClientAuth:   r.clientAuth,
+		MinVersion:   r.minVersion,
+		NextProtos:   []string{"h2"},
+	}, nil
+}
+
+// TLSCertificateExpiry returns NotAfter of current server certificate
+func TLSCertificateExpiry() (time.Time, bool) {
+	if serverCerts == nil {
+		return time.Time{}, false
+	}
+	serverCerts.mx.RLock()
+	defer serverCerts.mx.RUnlock()
+	return serverCerts.expiry, true
+}
+
+func parseClientAuth(s string) (tls.ClientAuthType, error) {
+	switch strings.ToLower(s) {
+	case "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.RequestClientCert, nil
+	case "require":
+		return tls.RequireAnyClientCert, nil
+	case "verify_if_given":
+		return tls.VerifyClientCertIfGiven, nil
+	case "", "require_and_verify":
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return tls.NoClientCert, errors.Errorf("unknown app.grpc.tls.client_auth %s", s)
+	}
+}
+
+func parseTLSVersion(s string) (uint16, error) {
+	switch s {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, errors.Errorf("unsupported app.grpc.tls.min_version %s", s)
+	}
+}
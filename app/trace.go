@@ -0,0 +1,48 @@
+package app
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"microservice/app/core"
+)
+
+// Metadata keys of trace, the same names are used in kafka headers
+const (
+	requestIdKey   = "x-request-id"
+	traceParentKey = "traceparent"
+	traceStateKey  = "tracestate"
+)
+
+// traceContext takes request id and traceparent from caller or starts new request id,
+// new traceparent is set by span of tracing interceptor. Request id is returned to caller in header.
+func traceContext(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+
+	m, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if values := m.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	trace := &core.Trace{
+		RequestId:   first(requestIdKey),
+		TraceParent: first(traceParentKey),
+		TraceState:  first(traceStateKey),
+	}
+	if trace.RequestId == "" || len(trace.RequestId) > 128 {
+		trace.RequestId = core.NewRequestId()
+	}
+	if !core.ValidTraceParent(trace.TraceParent) {
+		trace.TraceParent = ""
+		trace.TraceState = ""
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIdKey, trace.RequestId))
+
+	ctx = core.WithMethod(ctx, info.FullMethod)
+	ctx = core.WithIdentityHolder(ctx)
+	return handler(core.WithTrace(ctx, trace), req)
+}
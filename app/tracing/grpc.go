@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"microservice/app/core"
+)
+
+// UnaryServerInterceptor starts server span of call, it should follow interceptor which puts core.Trace
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+
+	service, method := "", strings.TrimPrefix(info.FullMethod, "/")
+	if i := strings.LastIndex(method, "/"); i >= 0 {
+		service, method = method[:i], method[i+1:]
+	}
+
+	ctx, span := Start(ctx, strings.TrimPrefix(info.FullMethod, "/"),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.service", service),
+			attribute.String("rpc.method", method),
+		),
+	)
+
+	resp, err = handler(ctx, req)
+
+	span.SetAttributes(attribute.Int("rpc.grpc.status_code", int(status.Code(err))))
+	// Errors are mostly returned in-band
+	if code := core.ResponseStatusCode(resp); code != "" {
+		span.SetAttributes(attribute.String("rpc.response.status_code", code))
+		if code == core.ServerError && err == nil {
+			span.SetStatus(codes.Error, code)
+		}
+	}
+	End(span, err)
+	return resp, err
+}
@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"runtime"
+	"strings"
+
+	trmsql "github.com/avito-tech/go-transaction-manager/sql"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SQL wraps connection or transaction, every query gets span named by the calling function, e.g. NewsRepo.FetchByPageNumber
+func SQL(tr trmsql.Tr) trmsql.Tr {
+	return &sqlTr{Tr: tr}
+}
+
+type sqlTr struct {
+	trmsql.Tr
+}
+
+func (t *sqlTr) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuery(ctx, query)
+	res, err := t.Tr.ExecContext(ctx, query, args...)
+	End(span, err)
+	return res, err
+}
+
+// QueryContext span ends when query is executed, reading of rows is not included
+func (t *sqlTr) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startQuery(ctx, query)
+	rows, err := t.Tr.QueryContext(ctx, query, args...)
+	End(span, err)
+	return rows, err
+}
+
+func (t *sqlTr) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuery(ctx, query)
+	row := t.Tr.QueryRowContext(ctx, query, args...)
+	err := row.Err()
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	End(span, err)
+	return row
+}
+
+func startQuery(ctx context.Context, query string) (context.Context, trace.Span) {
+	return Start(ctx, callerName(3),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.statement", strings.Join(strings.Fields(query), " ")),
+		),
+	)
+}
+
+// callerName returns e.g. NewsRepo.FetchByPageNumber for microservice/layers/repos.(*NewsRepo).FetchByPageNumber
+func callerName(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip)
+	if !ok {
+		return "sql"
+	}
+	name := runtime.FuncForPC(pc).Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.NewReplacer("(*", "", ")", "").Replace(name)
+}
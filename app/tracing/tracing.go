@@ -0,0 +1,156 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"microservice/app/core"
+)
+
+var (
+	tracer     = otel.Tracer("microservice")
+	propagator = propagation.TraceContext{}
+)
+
+// Init sets tracer provider from tracing config, returned func flushes spans on shutdown.
+// Without tracing.enabled spans are not recorded, but traceparent is still passed to kafka and logs.
+func Init() (func(context.Context) error, error) {
+
+	otel.SetTextMapPropagator(propagator)
+
+	if !viper.GetBool("tracing.enabled") {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, closer, err := newExporter()
+	if err != nil {
+		return nil, err
+	}
+
+	name := viper.GetString("tracing.service_name")
+	if name == "" {
+		name = "microservice"
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", name)))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create tracing resource")
+	}
+
+	ratio := 1.0
+	if viper.IsSet("tracing.sampling_ratio") {
+		ratio = viper.GetFloat64("tracing.sampling_ratio")
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// Decision of caller is respected, ratio is used for new traces only
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			_ = closer.Close()
+		}
+		return errors.Wrap(err, "cannot flush spans")
+	}, nil
+}
+
+// newExporter by tracing.exporter: otlp (gRPC collector), stdout or file
+func newExporter() (sdktrace.SpanExporter, io.Closer, error) {
+
+	switch exporter := viper.GetString("tracing.exporter"); exporter {
+	case "", "otlp":
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(viper.GetString("tracing.endpoint"))}
+		if viper.GetBool("tracing.insecure") {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		// Connection is made in background, collector may be started later
+		e, err := otlptracegrpc.New(context.Background(), opts...)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "cannot create OTLP exporter")
+		}
+		return e, nil, nil
+
+	case "stdout":
+		e, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "cannot create stdout exporter")
+		}
+		return e, nil, nil
+
+	case "file":
+		f, err := os.OpenFile(viper.GetString("tracing.file"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "cannot open tracing.file")
+		}
+		e, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			_ = f.Close()
+			return nil, nil, errors.Wrap(err, "cannot create file exporter")
+		}
+		return e, f, nil
+
+	default:
+		return nil, nil, errors.Errorf("unknown tracing.exporter %s", exporter)
+	}
+}
+
+// Start starts span, parent is span of ctx or traceparent of core.Trace (e.g. from kafka headers).
+// Trace of returned ctx points to the new span, so kafka messages and outbox become its children.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	t, hasTrace := core.TraceFromContext(ctx)
+	if hasTrace && t.TraceParent != "" && !trace.SpanContextFromContext(ctx).IsValid() {
+		ctx = propagator.Extract(ctx, propagation.MapCarrier{
+			"traceparent": t.TraceParent,
+			"tracestate":  t.TraceState,
+		})
+	}
+
+	ctx, span := tracer.Start(ctx, name, opts...)
+	if !hasTrace {
+		return ctx, span
+	}
+
+	next := *t
+	if span.SpanContext().IsValid() {
+		carrier := propagation.MapCarrier{}
+		propagator.Inject(ctx, carrier)
+		next.TraceParent, next.TraceState = carrier.Get("traceparent"), carrier.Get("tracestate")
+	} else if next.TraceParent == "" {
+		// Tracing is off, ids are still needed to link logs of request
+		next.TraceParent = core.NewTraceParent()
+	}
+	if next.RequestId != "" {
+		span.SetAttributes(attribute.String("request.id", next.RequestId))
+	}
+	return core.WithTrace(ctx, &next), span
+}
+
+// End records err (if any) and ends span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"os"
+	"time"
+)
+
+// watchFiles polls modification time of files and calls onChange when any of them was changed.
+// Polling (instead of inotify) also works with k8s secrets which are swapped by symlinks.
+func watchFiles(interval time.Duration, onChange func(), files ...string) {
+
+	modTimes := make([]time.Time, len(files))
+	for i, file := range files {
+		modTimes[i] = fileModTime(file)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			changed := false
+			for i, file := range files {
+				t := fileModTime(file)
+				if !t.Equal(modTimes[i]) {
+					modTimes[i] = t
+					changed = true
+				}
+			}
+			if changed {
+				onChange()
+			}
+		}
+	}()
+}
+
+func fileModTime(file string) time.Time {
+	info, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -1,16 +1,24 @@
 package bootstrap
 
 import (
+	"context"
 	"database/sql"
 	"microservice/app"
 	"microservice/app/core"
 	"microservice/app/job"
 	"microservice/app/kafka"
+	"microservice/app/metrics"
+	"microservice/app/rest"
+	"microservice/app/tracing"
+	"microservice/docs"
+	"time"
 
 	trmsql "github.com/avito-tech/go-transaction-manager/sql"
 	trmcontext "github.com/avito-tech/go-transaction-manager/trm/context"
 	"github.com/avito-tech/go-transaction-manager/trm/manager"
 	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.uber.org/dig"
 )
 
 func Run(rootPath ...string) error {
@@ -26,6 +34,13 @@ func Run(rootPath ...string) error {
 	if err != nil {
 		return errors.Wrap(err, "error while init logs")
 	}
+	defer app.CloseLogs()
+
+	// Tracing
+	shutdownTracing, err := tracing.Init()
+	if err != nil {
+		return errors.Wrap(err, "error while init tracing")
+	}
 
 	// Storage
 	err = app.InitStorage()
@@ -39,6 +54,12 @@ func Run(rootPath ...string) error {
 		return errors.Wrap(err, "error while init db")
 	}
 
+	if db != nil {
+		if err := metrics.RegisterDB(db, viper.GetString("db.name")); err != nil {
+			return errors.Wrap(err, "cannot register db metrics")
+		}
+	}
+
 	// Migrations
 	err = app.RunMigrations(rootPath...)
 	if err != nil {
@@ -60,20 +81,8 @@ func Run(rootPath ...string) error {
 		return errors.Wrap(err, "cannot provide db")
 	}
 
-	if err = di.Provide(func() *trmsql.CtxGetter {
-		return trmsql.DefaultCtxGetter
-	}); err != nil {
-		return errors.Wrap(err, "cannot provide tx getter")
-	}
-
-	trManager := manager.Must(
-		trmsql.NewDefaultFactory(db),
-		manager.WithCtxManager(trmcontext.DefaultManager),
-	)
-	if err = di.Provide(func() *manager.Manager {
-		return trManager
-	}); err != nil {
-		return errors.Wrap(err, "cannot provide tx manager")
+	if err = provideTransactions(di); err != nil {
+		return err
 	}
 
 	if err = di.Provide(func() core.Logger {
@@ -94,11 +103,23 @@ func Run(rootPath ...string) error {
 		return errors.Wrap(err, "cannot init kafka")
 	}
 
+	// REST admin API
+	if rest.Enabled() {
+		if err := rest.Init(); err != nil {
+			return errors.Wrap(err, "cannot init rest")
+		}
+	}
+
 	// CORE
 	if err := initDependencies(di); err != nil {
 		return errors.Wrap(err, "error while init dependencies")
 	}
 
+	// Events from outbox
+	if kafka.Enabled() {
+		job.NewSystemIntervalJob(job.NewOutboxRelayJob, viper.GetDuration("kafka.outbox.interval"))
+	}
+
 	//
 	//
 	// HERE CORE READY FOR WORK...
@@ -109,22 +130,71 @@ func Run(rootPath ...string) error {
 		return errors.Wrap(err, "error while start jobs")
 	}
 
+	// Probes and gRPC health, registered before servers start
+	app.RunHealthChecks(ctx)
+
 	// Run gRPC and block
 	go app.RunGRPCServer()
 
-	// Kafka init deps
-	//domain.UserScoreChangedTopic, err = kafka.Topic[*domain.UserScoreChangedEvent]("user_score_changed")
-	//if err != nil {
-	//	return errors.Wrap(err, "cannot initialize kafka")
-	//}
-	//
-	//polling, err := domain.UserScoreChangedTopic.StartPolling()
-	//if err != nil {
-	//	return errors.Wrap(err, "cannot initialize kafka")
-	//}
+	// REST gateway
+	app.HandleDocs(docs.Handler())
+	if viper.GetBool("app.metrics.enabled") {
+		app.HandleHTTP("/metrics", metrics.Handler())
+	}
+	go app.RunHTTPServer()
+
+	if rest.Enabled() {
+		go rest.RunServer()
+	}
 
 	// End context
 	<-ctx.Done()
 
+	// NOT_SERVING, then finish active calls
+	app.Shutdown()
+
+	// Admin requests and jobs (e.g. outbox batch) may produce to kafka, so they are finished before it is closed
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), app.ShutdownTimeout())
+	defer stopCancel()
+	if err := rest.Shutdown(stopCtx); err != nil {
+		logger.ErrorWrap(err, "cannot stop REST server gracefully")
+	}
+	if err := job.Stop(stopCtx); err != nil {
+		logger.ErrorWrap(err, "cannot stop jobs gracefully")
+	}
+
+	// Flush messages which are not sent yet
+	if err := kafka.Close(); err != nil {
+		return errors.Wrap(err, "cannot close kafka")
+	}
+
+	// Send spans which are not exported yet
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// provideTransactions provides tx manager for *sql.DB from container
+func provideTransactions(di *dig.Container) error {
+
+	if err := di.Provide(func() *trmsql.CtxGetter {
+		return trmsql.DefaultCtxGetter
+	}); err != nil {
+		return errors.Wrap(err, "cannot provide tx getter")
+	}
+
+	if err := di.Provide(func(db *sql.DB) *manager.Manager {
+		return manager.Must(
+			trmsql.NewDefaultFactory(db),
+			manager.WithCtxManager(trmcontext.DefaultManager),
+		)
+	}); err != nil {
+		return errors.Wrap(err, "cannot provide tx manager")
+	}
+
 	return nil
 }
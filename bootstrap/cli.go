@@ -0,0 +1,196 @@
+package bootstrap
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"microservice/app"
+	"microservice/app/core"
+	"microservice/app/kafka"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RunCommand executes maintenance command instead of starting server, e.g.
+//
+//	server kafka dlq list --topic news
+//	server kafka dlq replay --topic news --partition 0 --offset 15
+//	server kafka reset-offset --topic news --to timestamp --time 2023-10-01T00:00:00Z --dry-run
+func RunCommand(args []string, rootPath ...string) error {
+
+	if len(args) == 0 {
+		return errors.New("command is required")
+	}
+
+	// ENV, etc
+	_, cancel, err := app.InitApp(rootPath...)
+	if err != nil {
+		return errors.Wrap(err, "error while init app")
+	}
+	defer cancel()
+
+	// Logger
+	logger, err := app.InitLogs(rootPath...)
+	if err != nil {
+		return errors.Wrap(err, "error while init logs")
+	}
+	defer app.CloseLogs()
+
+	switch args[0] {
+	case "kafka":
+		// Offsets may be stored in bitcask or postgres, database is opened only if it is needed
+		err = app.InitStorage()
+		if err != nil {
+			return errors.Wrap(err, "error while init storage")
+		}
+		di := core.GetDI()
+		if err = di.Provide(app.InitDatabase); err != nil {
+			return errors.Wrap(err, "cannot provide db")
+		}
+		if err = provideTransactions(di); err != nil {
+			return err
+		}
+
+		err = kafka.InitKafka(logger)
+		if err != nil {
+			return errors.Wrap(err, "cannot init kafka")
+		}
+		if !kafka.Enabled() {
+			return errors.New("kafka is disabled in config")
+		}
+		defer kafka.Close()
+		return kafkaCommand(args[1:])
+	default:
+		return errors.Errorf("unknown command %s", args[0])
+	}
+}
+
+func kafkaCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: kafka <dlq|reset-offset>")
+	}
+	switch args[0] {
+	case "dlq":
+		return dlqCommand(args[1:])
+	case "reset-offset":
+		return resetOffsetCommand(args[1:])
+	default:
+		return errors.Errorf("unknown kafka command %s", args[0])
+	}
+}
+
+func dlqCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: kafka dlq <list|replay> --topic <topic>")
+	}
+
+	fs := flag.NewFlagSet("dlq "+args[0], flag.ContinueOnError)
+	topic := fs.String("topic", "", "original topic, messages are read from <topic>.dlq")
+	limit := fs.Int("limit", 100, "max messages to list, 0 means all")
+	partition := fs.Int("partition", -1, "replay only messages of this dlq partition")
+	offset := fs.Int64("offset", -1, "replay only message with this dlq offset, even if it was replayed")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *topic == "" {
+		return errors.New("--topic is required")
+	}
+
+	switch args[0] {
+	case "list":
+		letters, err := kafka.ListDLQ(*topic, *limit)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PARTITION\tOFFSET\tTIME\tKEY\tORIGIN\tATTEMPTS\tERROR")
+		for _, l := range letters {
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s/%d/%d\t%d\t%s\n",
+				l.Partition, l.Offset, l.Timestamp.Format("2006-01-02 15:04:05"), string(l.Key),
+				l.OriginalTopic, l.OriginalPartition, l.OriginalOffset, l.Attempts,
+				strings.ReplaceAll(l.Error, "\n", " "))
+		}
+		return w.Flush()
+
+	case "replay":
+		// Single message is replayed explicitly, others only once after saved replay offset
+		if *offset >= 0 {
+			if *partition < 0 {
+				return errors.New("--partition is required with --offset")
+			}
+			if err := kafka.ReplayDLQMessage(*topic, int32(*partition), *offset); err != nil {
+				return err
+			}
+			fmt.Printf("Replayed message %d/%d to %s\n", *partition, *offset, *topic)
+			return nil
+		}
+
+		replayed, err := kafka.ReplayDLQ(*topic, int32(*partition))
+		fmt.Printf("Replayed %d messages to %s\n", replayed, *topic)
+		return err
+
+	default:
+		return errors.Errorf("unknown dlq command %s", args[0])
+	}
+}
+
+func resetOffsetCommand(args []string) error {
+
+	fs := flag.NewFlagSet("reset-offset", flag.ContinueOnError)
+	topic := fs.String("topic", "", "topic to reset")
+	to := fs.String("to", "", "earliest | latest | offset | timestamp")
+	offset := fs.Int64("offset", 0, "target offset for --to offset")
+	timestamp := fs.String("time", "", "target time in RFC3339 for --to timestamp")
+	partition := fs.Int("partition", -1, "reset only this partition")
+	dryRun := fs.Bool("dry-run", false, "print positions without saving")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *topic == "" || *to == "" {
+		return errors.New("--topic and --to are required")
+	}
+
+	req := kafka.ResetRequest{
+		Topic:     *topic,
+		To:        *to,
+		Offset:    *offset,
+		Partition: int32(*partition),
+		DryRun:    *dryRun,
+	}
+	if *to == kafka.ResetTimestamp {
+		t, err := time.Parse(time.RFC3339, *timestamp)
+		if err != nil {
+			return errors.Wrap(err, "--time must be in RFC3339")
+		}
+		req.Timestamp = t
+	}
+
+	resets, err := kafka.ResetOffsets(context.Background(), req)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PARTITION\tCURRENT\tTARGET\tOLDEST\tNEWEST\tMESSAGES")
+	for _, r := range resets {
+		current := "-"
+		if r.Current != nil {
+			current = strconv.FormatInt(*r.Current, 10)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%d\t%d\n", r.Partition, current, r.Target, r.Oldest, r.Newest, r.Messages)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if *dryRun {
+		fmt.Println("Dry run, offsets were not changed")
+	}
+	return nil
+}
@@ -2,9 +2,13 @@ package bootstrap
 
 import (
 	"microservice/app"
+	"microservice/app/rest"
 	"microservice/layers/delivery/grpc"
+	"microservice/layers/delivery/kafka"
+	restdelivery "microservice/layers/delivery/rest"
 	"microservice/layers/domain"
 	"microservice/layers/repos"
+	"microservice/layers/services"
 	"microservice/layers/usecase"
 
 	"go.uber.org/dig"
@@ -14,15 +18,27 @@ func initDependencies(di *dig.Container) error {
 
 	// Repository
 	_ = di.Provide(repos.NewNewsrepo, dig.As(new(domain.NewsRepository)))
+	_ = di.Provide(repos.NewOutboxRepo, dig.As(new(domain.OutboxRepository)))
+	_ = di.Provide(repos.NewUserDataRepo, dig.As(new(domain.UserDataRepository)))
 
 	// Services
+	_ = di.Provide(services.NewNewsEventsPublisher, dig.As(new(domain.NewsEventPublisher)))
 
 	// Use Cases
 	_ = di.Provide(usecase.NewNewsUseCase, dig.As(new(domain.NewsUseCase)))
+	_ = di.Provide(usecase.NewUserUseCase, dig.As(new(domain.UserUseCase)))
 
 	//delivery
 	if err := app.InitDelivery(grpc.NewNewsService); err != nil {
 		return err
 	}
+	if err := app.InitDelivery(kafka.NewUserEventsService); err != nil {
+		return err
+	}
+	if rest.Enabled() {
+		if err := rest.InitDelivery("/admin/news", restdelivery.NewNewsAdminService); err != nil {
+			return err
+		}
+	}
 	return nil
 }
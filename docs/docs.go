@@ -0,0 +1,17 @@
+package docs
+
+import (
+	"embed"
+	"net/http"
+)
+
+// news.swagger.json is generated by protoc-gen-openapiv2, see ReadMe.
+// swagger-ui is copied from swagger-ui-dist 4.14.3, so docs work without access to CDN.
+//
+//go:embed news.swagger.json index.html swagger-ui
+var files embed.FS
+
+// Handler serves Swagger UI and OpenAPI spec, it should be mounted on /docs/
+func Handler() http.Handler {
+	return http.StripPrefix("/docs/", http.FileServer(http.FS(files)))
+}
@@ -26,6 +26,15 @@ func NewNewsService(log core.Logger, newsUCase domain.NewsUseCase) *NewsDelivery
 
 func (d *NewsDeliveryService) Init() error {
 	app.InitGRPCService(pb.RegisterNewsServiceServer, pb.NewsServiceServer(d))
+	if err := app.InitGRPCGateway(pb.RegisterNewsServiceHandlerFromEndpoint); err != nil {
+		return err
+	}
+
+	// Mutations are allowed only for admins
+	app.RequireRole("/pb.NewsService/AddNewsCard", core.RoleSuperAdmin)
+	app.RequireRole("/pb.NewsService/AddNewsDetails", core.RoleSuperAdmin)
+	app.RequireRole("/pb.NewsService/DeleteNewsCard", core.RoleSuperAdmin)
+	app.RequireRole("/pb.NewsService/DeleteNewsDetails", core.RoleSuperAdmin)
 	return nil
 }
 
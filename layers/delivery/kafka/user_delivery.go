@@ -0,0 +1,51 @@
+package kafka
+
+import (
+	"context"
+	"microservice/app/core"
+	"microservice/app/kafka"
+	"microservice/layers/domain"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+type UserEventsDeliveryService struct {
+	log       core.Logger
+	userUcase domain.UserUseCase
+}
+
+func NewUserEventsService(log core.Logger, userUcase domain.UserUseCase) *UserEventsDeliveryService {
+	return &UserEventsDeliveryService{
+		log:       log,
+		userUcase: userUcase,
+	}
+}
+
+func (d *UserEventsDeliveryService) Init() error {
+
+	if !kafka.Enabled() {
+		d.log.Warn("Kafka is disabled, user events will not be consumed")
+		return nil
+	}
+
+	name := viper.GetString("kafka.events.users.deleted_topic")
+	if name == "" {
+		name = "user_deleted"
+	}
+
+	topic, err := kafka.Topic[*domain.UserDeletedEvent](name)
+	if err != nil {
+		return errors.Wrapf(err, "cannot init topic %s", name)
+	}
+
+	// Stopped by kafka.Close on shutdown
+	return topic.Subscribe(context.Background(), d.UserDeleted)
+}
+
+func (d *UserEventsDeliveryService) UserDeleted(ctx context.Context, msg *kafka.Message[*domain.UserDeletedEvent]) error {
+	if msg.Value == nil {
+		return errors.New("empty user_deleted event")
+	}
+	return d.userUcase.EraseUser(ctx, msg.Value.UserId)
+}
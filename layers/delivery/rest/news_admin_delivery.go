@@ -0,0 +1,286 @@
+package rest
+
+import (
+	"microservice/app/core"
+	"microservice/app/rest"
+	"microservice/layers/domain"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NewsAdminDeliveryService struct {
+	log       core.Logger
+	newsUcase domain.NewsUseCase
+}
+
+func NewNewsAdminService(log core.Logger, newsUCase domain.NewsUseCase) *NewsAdminDeliveryService {
+	return &NewsAdminDeliveryService{
+		log:       log,
+		newsUcase: newsUCase,
+	}
+}
+
+// Requests
+
+type pageQuery struct {
+	Page int32 `form:"page" validate:"omitempty,gt=0"`
+}
+
+type idUri struct {
+	Id int32 `uri:"id" validate:"required,gt=0"`
+}
+
+type newsCardRequest struct {
+	Title string `json:"title" validate:"required,max=255"`
+	Image string `json:"image" validate:"required,max=255"`
+}
+
+type newsDetailsRequest struct {
+	Data []newsDetailsItem `json:"data" validate:"required,min=1,dive"`
+}
+
+type newsDetailsItem struct {
+	Title      string `json:"title" validate:"required,max=255"`
+	Image      string `json:"image" validate:"required,max=255"`
+	SwipeDelay int32  `json:"swipe_delay" validate:"required,gt=0"`
+}
+
+type reorderRequest struct {
+	Ids []int32 `json:"ids" validate:"required,min=1,unique,dive,gt=0"`
+}
+
+// Responses
+
+type newsCard struct {
+	Id        int32     `json:"id"`
+	Title     string    `json:"title"`
+	Image     string    `json:"image"`
+	Type      string    `json:"type"`
+	IsActive  bool      `json:"is_active"`
+	Position  int32     `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newNewsCard(card *domain.NewsCard) *newsCard {
+	return &newsCard{
+		Id:        card.Id,
+		Title:     card.Title,
+		Image:     card.Image,
+		Type:      card.Type,
+		IsActive:  card.IsActive,
+		Position:  card.Position,
+		CreatedAt: card.CreatedAt,
+		UpdatedAt: card.UpdatedAt,
+	}
+}
+
+func (d *NewsAdminDeliveryService) Route(r *gin.RouterGroup) error {
+	r.Use(rest.GeneralMW, rest.TraceMW, rest.ErrorMW, rest.RequireRole(d.log, core.RoleSuperAdmin))
+
+	r.GET("", d.GetNews)
+	r.POST("", d.AddNewsCard)
+	r.POST("/reorder", d.ReorderNews)
+	r.GET("/:id", d.GetNewsCard)
+	r.PUT("/:id", d.UpdateNewsCard)
+	r.DELETE("/:id", d.DeleteNewsCard)
+	r.POST("/:id/publish", d.PublishNewsCard)
+	r.POST("/:id/details", d.AddNewsDetails)
+	r.DELETE("/details/:id", d.DeleteNewsDetails)
+	return nil
+}
+
+func (d *NewsAdminDeliveryService) GetNews(ctx *gin.Context) {
+	query := pageQuery{Page: 1}
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	uCaseRes, err := d.newsUcase.GetAllNews(ctx.Request.Context(), query.Page)
+	if err != nil {
+		d.serverError(ctx, err)
+		return
+	}
+
+	data := make([]*newsCard, 0, len(uCaseRes.News))
+	for _, card := range uCaseRes.News {
+		data = append(data, newNewsCard(card))
+	}
+
+	ctx.JSON(httpStatus(uCaseRes.Status), core.DataResponse[[]*newsCard]{
+		Status: status(uCaseRes.Status),
+		Data:   data,
+	})
+}
+
+func (d *NewsAdminDeliveryService) GetNewsCard(ctx *gin.Context) {
+	var uri idUri
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	uCaseRes, err := d.newsUcase.GetNewsCard(ctx.Request.Context(), uri.Id)
+	if err != nil {
+		d.serverError(ctx, err)
+		return
+	}
+
+	var data *newsCard
+	if uCaseRes.News != nil {
+		data = newNewsCard(uCaseRes.News)
+	}
+
+	ctx.JSON(httpStatus(uCaseRes.Status), core.DataResponse[*newsCard]{
+		Status: status(uCaseRes.Status),
+		Data:   data,
+	})
+}
+
+func (d *NewsAdminDeliveryService) AddNewsCard(ctx *gin.Context) {
+	var req newsCardRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	uCaseRes, err := d.newsUcase.AddNewsCard(ctx.Request.Context(), domain.NewsCard{
+		Title: req.Title,
+		Image: req.Image,
+	})
+	if err != nil {
+		d.serverError(ctx, err)
+		return
+	}
+
+	ctx.JSON(httpStatus(uCaseRes.Status), core.IdResponse{
+		Status: status(uCaseRes.Status),
+		Id:     uCaseRes.Id,
+	})
+}
+
+func (d *NewsAdminDeliveryService) UpdateNewsCard(ctx *gin.Context) {
+	var uri idUri
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	var req newsCardRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	uCaseRes, err := d.newsUcase.UpdateNewsCard(ctx.Request.Context(), domain.NewsCard{
+		Id:    uri.Id,
+		Title: req.Title,
+		Image: req.Image,
+	})
+	d.statusResponse(ctx, uCaseRes, err)
+}
+
+func (d *NewsAdminDeliveryService) DeleteNewsCard(ctx *gin.Context) {
+	var uri idUri
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	uCaseRes, err := d.newsUcase.DeleteNewsCard(ctx.Request.Context(), uri.Id)
+	d.statusResponse(ctx, uCaseRes, err)
+}
+
+func (d *NewsAdminDeliveryService) PublishNewsCard(ctx *gin.Context) {
+	var uri idUri
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	uCaseRes, err := d.newsUcase.PublishNewsCard(ctx.Request.Context(), uri.Id)
+	d.statusResponse(ctx, uCaseRes, err)
+}
+
+func (d *NewsAdminDeliveryService) ReorderNews(ctx *gin.Context) {
+	var req reorderRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	uCaseRes, err := d.newsUcase.ReorderNews(ctx.Request.Context(), req.Ids)
+	d.statusResponse(ctx, uCaseRes, err)
+}
+
+func (d *NewsAdminDeliveryService) AddNewsDetails(ctx *gin.Context) {
+	var uri idUri
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	var req newsDetailsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	details := make([]*domain.NewsDetails, 0, len(req.Data))
+	for _, item := range req.Data {
+		details = append(details, &domain.NewsDetails{
+			Title:      item.Title,
+			Image:      item.Image,
+			NewsID:     uri.Id,
+			SwipeDelay: item.SwipeDelay,
+		})
+	}
+
+	uCaseRes, err := d.newsUcase.AddNewsDetails(ctx.Request.Context(), details, uri.Id)
+	d.statusResponse(ctx, uCaseRes.Status, err)
+}
+
+func (d *NewsAdminDeliveryService) DeleteNewsDetails(ctx *gin.Context) {
+	var uri idUri
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	uCaseRes, err := d.newsUcase.DeleteNewsDetails(ctx.Request.Context(), uri.Id)
+	d.statusResponse(ctx, uCaseRes, err)
+}
+
+func (d *NewsAdminDeliveryService) statusResponse(ctx *gin.Context, s domain.Status, err error) {
+	if err != nil {
+		d.serverError(ctx, err)
+		return
+	}
+	ctx.JSON(httpStatus(s), core.StatusResponse{Status: status(s)})
+}
+
+func (d *NewsAdminDeliveryService) serverError(ctx *gin.Context, err error) {
+	d.log.ErrorWrap(err, "%s %s", ctx.Request.Method, ctx.FullPath())
+	ctx.JSON(500, rest.ServerError())
+}
+
+func status(s domain.Status) core.Status {
+	return core.Status{
+		Code:    s.Code,
+		Message: s.Message,
+	}
+}
+
+func httpStatus(s domain.Status) int {
+	switch s.Code {
+	case domain.Success:
+		return 200
+	case domain.ValidationError, domain.FieldRequired:
+		return 400
+	case domain.NotFound:
+		return 404
+	case domain.AlreadyExists:
+		return 409
+	}
+	return 500
+}
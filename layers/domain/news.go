@@ -31,6 +31,7 @@ type NewsCard struct {
 	Image    string
 	Type     string
 	IsActive bool
+	Position int32 // порядок в ленте, по возрастанию
 
 	UpdatedAt time.Time
 	CreatedAt time.Time
@@ -51,6 +52,33 @@ type NewsDetails struct {
 	DeletedAt *time.Time
 }
 
+// EVENTS
+const (
+	NewsCreatedEvent   string = "news.created"
+	NewsPublishedEvent string = "news.published"
+	NewsUpdatedEvent   string = "news.updated"
+	NewsDeletedEvent   string = "news.deleted"
+
+	// Увеличивается при несовместимых изменениях схемы NewsEvent
+	NewsEventVersion int = 1
+)
+
+// Событие жизненного цикла новости, ключ сообщения - id новости
+type NewsEvent struct {
+	Type       string         `json:"type"`
+	Version    int            `json:"version"`
+	NewsId     int32          `json:"news_id"`
+	OccurredAt time.Time      `json:"occurred_at"`
+	News       *NewsEventData `json:"news,omitempty"`
+}
+
+type NewsEventData struct {
+	Title    string `json:"title"`
+	Image    string `json:"image"`
+	Type     string `json:"type"`
+	IsActive bool   `json:"is_active"`
+}
+
 // REPOSITORIES
 type NewsRepository interface {
 	FetchByPageNumber(ctx context.Context, page int32) ([]*NewsCard, error)
@@ -58,7 +86,19 @@ type NewsRepository interface {
 	InsertIfNotExistsNewsCard(ctx context.Context, newsCard *NewsCard) (int32, error)
 	InsertIfNotExistsNewsDetails(ctx context.Context, newsDetails []*NewsDetails, news_id int32) error
 	DeleteNewsCard(ctx context.Context, id int32) error
-	DeleteNewsDetails(ctx context.Context, id int32) error
+	DeleteNewsDetails(ctx context.Context, id int32) (int32, error)
+
+	// Для админки: неактивные новости тоже возвращаются
+	FetchAllByPageNumber(ctx context.Context, page int32) ([]*NewsCard, error)
+	FetchNewsCard(ctx context.Context, id int32) (*NewsCard, error)
+	UpdateNewsCard(ctx context.Context, card *NewsCard) (bool, error)
+	PublishNewsCard(ctx context.Context, id int32) error
+	ReorderNews(ctx context.Context, ids []int32) (int64, error)
+}
+
+// SERVICES
+type NewsEventPublisher interface {
+	Publish(ctx context.Context, event *NewsEvent) error
 }
 
 // USE CASES
@@ -69,6 +109,13 @@ type NewsUseCase interface {
 	AddNewsDetails(ctx context.Context, newsDetails []*NewsDetails, news_id int32) (CreateNewsDetailesResponse, error)
 	DeleteNewsCard(ctx context.Context, id int32) (Status, error)
 	DeleteNewsDetails(ctx context.Context, id int32) (Status, error)
+
+	// Для админки
+	GetAllNews(ctx context.Context, page int32) (GetNewsResponse, error)
+	GetNewsCard(ctx context.Context, id int32) (GetNewsCardResponse, error)
+	UpdateNewsCard(ctx context.Context, newsCard NewsCard) (Status, error)
+	PublishNewsCard(ctx context.Context, id int32) (Status, error)
+	ReorderNews(ctx context.Context, ids []int32) (Status, error)
 }
 
 // Response
@@ -77,6 +124,11 @@ type GetNewsResponse struct {
 	News   []*NewsCard
 }
 
+type GetNewsCardResponse struct {
+	Status Status
+	News   *NewsCard
+}
+
 type GetNewsDetailsResponse struct {
 	Status      Status
 	NewsDetails []*NewsDetails
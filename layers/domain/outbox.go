@@ -0,0 +1,17 @@
+package domain
+
+import "context"
+
+// Сообщение, которое будет отправлено в kafka после коммита транзакции
+type OutboxMessage struct {
+	Topic   string
+	Key     string
+	Payload []byte
+	Headers map[string]string
+}
+
+// REPOSITORIES
+type OutboxRepository interface {
+	// Add должен вызываться в той же транзакции, что и изменения данных
+	Add(ctx context.Context, msg *OutboxMessage) error
+}
@@ -0,0 +1,23 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Событие сервиса авторизации об удалении пользователя, ключ сообщения - id пользователя
+type UserDeletedEvent struct {
+	UserId    int64     `json:"user_id"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// REPOSITORIES
+type UserDataRepository interface {
+	// EraseUser удаляет данные пользователя и возвращает false, если они уже были удалены раньше
+	EraseUser(ctx context.Context, userId int64, requestId string) (bool, error)
+}
+
+// USE CASES
+type UserUseCase interface {
+	EraseUser(ctx context.Context, userId int64) error
+}
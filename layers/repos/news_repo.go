@@ -5,30 +5,41 @@ import (
 	"database/sql"
 	"fmt"
 	"microservice/app/core"
+	"microservice/app/tracing"
 	"microservice/layers/domain"
 
+	trmsql "github.com/avito-tech/go-transaction-manager/sql"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 )
 
 type NewsRepo struct {
-	log core.Logger
-	db  *sql.DB
+	log    core.Logger
+	db     *sql.DB
+	getter *trmsql.CtxGetter
 }
 
-func NewNewsrepo(log core.Logger, db *sql.DB) *NewsRepo {
+func NewNewsrepo(log core.Logger, db *sql.DB, getter *trmsql.CtxGetter) *NewsRepo {
 	return &NewsRepo{
-		log: log,
-		db:  db,
+		log:    log,
+		db:     db,
+		getter: getter,
 	}
 }
 
+// conn returns transaction from context or db, queries are traced
+func (r *NewsRepo) conn(ctx context.Context) trmsql.Tr {
+	return tracing.SQL(r.getter.DefaultTrOrDB(ctx, r.db))
+}
+
 func (r *NewsRepo) FetchByPageNumber(ctx context.Context, page int32) ([]*domain.NewsCard, error) {
 
 	query := fmt.Sprintf(`SELECT id, title, image, type, created_at, updated_at, deleted_at FROM news 
 						  WHERE deleted_at IS NULL and is_active is TRUE 
+						  ORDER BY position, id
 						  LIMIT %d OFFSET %d; `, page*10, (page-1)*10)
 
-	rows, err := r.db.QueryContext(ctx, query)
+	rows, err := r.conn(ctx).QueryContext(ctx, query)
 	if err != nil {
 		return []*domain.NewsCard{}, errors.Wrap(err, "Query while FetchByPageNumber")
 	}
@@ -56,7 +67,7 @@ func (r *NewsRepo) FetchNewsDetailsByPageNumber(ctx context.Context, page int32,
 								WHERE nd.deleted_at is null and nd.is_active = true and nd.news_id = %d
 								LIMIT %d OFFSET %d; `, news_id, page*10, (page-1)*10)
 
-	rows, err := r.db.QueryContext(ctx, query)
+	rows, err := r.conn(ctx).QueryContext(ctx, query)
 	if err != nil {
 		return []*domain.NewsDetails{}, errors.Wrap(err, "Query while FetchByPageNumber")
 	}
@@ -85,11 +96,12 @@ func (r *NewsRepo) InsertIfNotExistsNewsCard(ctx context.Context, card *domain.N
 	}
 
 	// Создаём карточку новости
+	// Тип и активность берём из сохранённой строки, по ним строятся события
 	query := fmt.Sprintf(`INSERT INTO news (title, image, type, is_active) 
-						  VALUES ('%s', '%s', '%s', false) returning id;`,
+						  VALUES ('%s', '%s', '%s', false) returning id, type, coalesce(is_active, false);`,
 		card.Title, card.Image, type_default)
 
-	err := r.db.QueryRowContext(ctx, query).Scan(&card.Id)
+	err := r.conn(ctx).QueryRowContext(ctx, query).Scan(&card.Id, &card.Type, &card.IsActive)
 	if err != nil {
 
 		errors.Wrap(err, "Query while InsertIfNotExists")
@@ -125,9 +137,8 @@ func (r NewsRepo) InsertIfNotExistsNewsDetails(ctx context.Context, newsDetails
 			query += "),"
 		}
 	}
-	query += " returning id;"
 
-	_, err := r.db.QueryContext(ctx, query)
+	_, err := r.conn(ctx).ExecContext(ctx, query)
 	if err != nil {
 		return errors.Wrap(err, "Query while InsertNews Details")
 	}
@@ -141,29 +152,173 @@ func (r NewsRepo) DeleteNewsCard(ctx context.Context, id int32) error {
 						  set deleted_at = now() 
 						  where id = %d`, id)
 
-	_, err := r.db.QueryContext(ctx, query)
+	_, err := r.conn(ctx).ExecContext(ctx, query)
 	if err != nil {
-
-		errors.Wrap(err, "Query while DeleteNewsDetails")
-		return err
+		return errors.Wrap(err, "Query while DeleteNewsCard")
 	}
 
 	return nil
 
 }
 
-func (r NewsRepo) DeleteNewsDetails(ctx context.Context, id int32) error {
+func (r NewsRepo) DeleteNewsDetails(ctx context.Context, id int32) (int32, error) {
 
 	query := fmt.Sprintf(`update news_details 
 						  set deleted_at = now() 
-						  where id = %d`, id)
+						  where id = %d
+						  returning news_id`, id)
+
+	var newsId int32
+	err := r.conn(ctx).QueryRowContext(ctx, query).Scan(&newsId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "Query while DeleteNewsDetails")
+	}
+
+	return newsId, nil
+}
+
+// FetchAllByPageNumber returns active and inactive cards in feed order
+func (r *NewsRepo) FetchAllByPageNumber(ctx context.Context, page int32) ([]*domain.NewsCard, error) {
+
+	query := `SELECT id, title, image, type, coalesce(is_active, false), position, created_at, updated_at FROM news
+			  WHERE deleted_at IS NULL
+			  ORDER BY position, id
+			  LIMIT 10 OFFSET $1`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, (page-1)*10)
+	if err != nil {
+		return nil, errors.Wrap(err, "Query while FetchAllByPageNumber")
+	}
+	defer rows.Close()
+
+	var result []*domain.NewsCard
+	for rows.Next() {
+		var card domain.NewsCard
+		err := rows.Scan(&card.Id, &card.Title, &card.Image, &card.Type, &card.IsActive, &card.Position, &card.CreatedAt, &card.UpdatedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "Scan while FetchAllByPageNumber")
+		}
+		result = append(result, &card)
+	}
+
+	return result, errors.Wrap(rows.Err(), "Rows while FetchAllByPageNumber")
+}
+
+// FetchNewsCard returns nil if card doesn't exist or is deleted
+func (r *NewsRepo) FetchNewsCard(ctx context.Context, id int32) (*domain.NewsCard, error) {
+
+	query := `SELECT id, title, image, type, coalesce(is_active, false), position, created_at, updated_at FROM news
+			  WHERE id = $1 AND deleted_at IS NULL`
+
+	var card domain.NewsCard
+	err := r.conn(ctx).QueryRowContext(ctx, query, id).
+		Scan(&card.Id, &card.Title, &card.Image, &card.Type, &card.IsActive, &card.Position, &card.CreatedAt, &card.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Query while FetchNewsCard")
+	}
+
+	return &card, nil
+}
 
-	_, err := r.db.QueryContext(ctx, query)
+// UpdateNewsCard changes title and image, the rest of card is filled from db. Returns false if card doesn't exist.
+func (r *NewsRepo) UpdateNewsCard(ctx context.Context, card *domain.NewsCard) (bool, error) {
+
+	query := `UPDATE news
+			  SET title = $2, image = $3, updated_at = now()
+			  WHERE id = $1 AND deleted_at IS NULL
+			  RETURNING type, coalesce(is_active, false), position, created_at, updated_at`
+
+	err := r.conn(ctx).QueryRowContext(ctx, query, card.Id, card.Title, card.Image).
+		Scan(&card.Type, &card.IsActive, &card.Position, &card.CreatedAt, &card.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
 	if err != nil {
+		return false, errors.Wrap(err, "Query while UpdateNewsCard")
+	}
 
-		errors.Wrap(err, "Query while DeleteNewsDetails")
-		return err
+	return true, nil
+}
+
+func (r *NewsRepo) PublishNewsCard(ctx context.Context, id int32) error {
+
+	query := `UPDATE news
+			  SET is_active = true, updated_at = now()
+			  WHERE id = $1 AND deleted_at IS NULL`
+
+	_, err := r.conn(ctx).ExecContext(ctx, query, id)
+	if err != nil {
+		return errors.Wrap(err, "Query while PublishNewsCard")
 	}
 
 	return nil
 }
+
+// ReorderNews ставит ids в порядке списка на места, которые они уже занимают в ленте,
+// остальные новости остаются на своих местах. Возвращает число найденных ids, без всех ничего не меняется.
+func (r *NewsRepo) ReorderNews(ctx context.Context, ids []int32) (int64, error) {
+
+	// Текущий порядок ленты, строки блокируются до конца транзакции
+	rows, err := r.conn(ctx).QueryContext(ctx, `SELECT id FROM news WHERE deleted_at IS NULL
+						ORDER BY position, id FOR UPDATE`)
+	if err != nil {
+		return 0, errors.Wrap(err, "Query while ReorderNews")
+	}
+	defer rows.Close()
+
+	var current []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return 0, errors.Wrap(err, "Scan while ReorderNews")
+		}
+		current = append(current, id)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, errors.Wrap(err, "Rows while ReorderNews")
+	}
+
+	order, found := reorder(current, ids)
+	if found != len(ids) {
+		return int64(found), nil
+	}
+
+	// Позиции 1..n у всех новостей, так у них нет одинаковых позиций
+	query := `UPDATE news
+			  SET position = x.position, updated_at = now()
+			  FROM unnest($1::int[]) WITH ORDINALITY AS x(id, position)
+			  WHERE news.id = x.id AND news.position IS DISTINCT FROM x.position`
+
+	_, err = r.conn(ctx).ExecContext(ctx, query, pq.Array(order))
+	if err != nil {
+		return 0, errors.Wrap(err, "Query while ReorderNews")
+	}
+
+	return int64(found), nil
+}
+
+// reorder ставит ids на занятые ими места в current в порядке ids, возвращает новый порядок и число найденных ids
+func reorder(current []int32, ids []int32) ([]int32, int) {
+	moved := make(map[int32]bool, len(ids))
+	for _, id := range ids {
+		moved[id] = true
+	}
+
+	order := make([]int32, len(current))
+	found := 0
+	for i, id := range current {
+		if moved[id] && found < len(ids) {
+			order[i] = ids[found]
+			found++
+			continue
+		}
+		order[i] = id
+	}
+	return order, found
+}
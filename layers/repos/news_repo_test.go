@@ -0,0 +1,57 @@
+package repos
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReorder(t *testing.T) {
+	tests := []struct {
+		name    string
+		current []int32
+		ids     []int32
+		order   []int32
+		found   int
+	}{
+		{
+			name:    "all",
+			current: []int32{1, 2, 3},
+			ids:     []int32{3, 1, 2},
+			order:   []int32{3, 1, 2},
+			found:   3,
+		},
+		{
+			name:    "subset keeps other cards in place",
+			current: []int32{1, 2, 3, 4, 5},
+			ids:     []int32{4, 2},
+			order:   []int32{1, 4, 3, 2, 5},
+			found:   2,
+		},
+		{
+			name:    "subset in the same order",
+			current: []int32{1, 2, 3, 4},
+			ids:     []int32{1, 3},
+			order:   []int32{1, 2, 3, 4},
+			found:   2,
+		},
+		{
+			name:    "unknown id",
+			current: []int32{1, 2, 3},
+			ids:     []int32{3, 7},
+			order:   []int32{1, 2, 3},
+			found:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, found := reorder(tt.current, tt.ids)
+			if found != tt.found {
+				t.Errorf("found = %d, want %d", found, tt.found)
+			}
+			if found == len(tt.ids) && !reflect.DeepEqual(order, tt.order) {
+				t.Errorf("order = %v, want %v", order, tt.order)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"microservice/app/core"
+	"microservice/layers/domain"
+
+	trmsql "github.com/avito-tech/go-transaction-manager/sql"
+	"github.com/pkg/errors"
+)
+
+type OutboxRepo struct {
+	log    core.Logger
+	db     *sql.DB
+	getter *trmsql.CtxGetter
+}
+
+func NewOutboxRepo(log core.Logger, db *sql.DB, getter *trmsql.CtxGetter) *OutboxRepo {
+	return &OutboxRepo{
+		log:    log,
+		db:     db,
+		getter: getter,
+	}
+}
+
+func (r *OutboxRepo) Add(ctx context.Context, msg *domain.OutboxMessage) error {
+
+	headers, err := json.Marshal(msg.Headers)
+	if err != nil {
+		return errors.Wrap(err, "Marshal headers while Add outbox")
+	}
+
+	query := `INSERT INTO outbox (topic, message_key, payload, headers) VALUES ($1, $2, $3, $4)`
+	_, err = r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, msg.Topic, msg.Key, msg.Payload, headers)
+	if err != nil {
+		return errors.Wrap(err, "Query while Add outbox")
+	}
+	return nil
+}
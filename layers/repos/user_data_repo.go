@@ -0,0 +1,42 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"microservice/app/core"
+
+	trmsql "github.com/avito-tech/go-transaction-manager/sql"
+	"github.com/pkg/errors"
+)
+
+type UserDataRepo struct {
+	log    core.Logger
+	db     *sql.DB
+	getter *trmsql.CtxGetter
+}
+
+func NewUserDataRepo(log core.Logger, db *sql.DB, getter *trmsql.CtxGetter) *UserDataRepo {
+	return &UserDataRepo{
+		log:    log,
+		db:     db,
+		getter: getter,
+	}
+}
+
+// Сейчас сервис не хранит данных пользователей (просмотры, реакции, голоса, закладки),
+// поэтому только фиксируется факт удаления. Новые таблицы с user_id нужно очищать здесь,
+// в той же транзакции до записи в user_erasures.
+func (r *UserDataRepo) EraseUser(ctx context.Context, userId int64, requestId string) (bool, error) {
+
+	query := `INSERT INTO user_erasures (user_id, request_id) VALUES ($1, $2) ON CONFLICT (user_id) DO NOTHING`
+	res, err := r.getter.DefaultTrOrDB(ctx, r.db).ExecContext(ctx, query, userId, requestId)
+	if err != nil {
+		return false, errors.Wrap(err, "Query while EraseUser")
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "RowsAffected while EraseUser")
+	}
+	return rows != 0, nil
+}
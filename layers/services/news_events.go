@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"microservice/app/core"
+	"microservice/app/kafka"
+	"microservice/layers/domain"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// NewsEventsPublisher writes events to outbox, they are sent to kafka by outbox relay job
+type NewsEventsPublisher struct {
+	log    core.Logger
+	outbox domain.OutboxRepository
+	topic  string
+	types  map[string]bool
+}
+
+// NewNewsEventsPublisher returns no-op publisher when kafka is disabled
+func NewNewsEventsPublisher(log core.Logger, outbox domain.OutboxRepository) *NewsEventsPublisher {
+
+	if !kafka.Enabled() {
+		log.Warn("Kafka is disabled, news events will not be published")
+		return &NewsEventsPublisher{log: log}
+	}
+
+	topic := viper.GetString("kafka.events.news.topic")
+	if topic == "" {
+		topic = "news"
+	}
+
+	// Empty list means all events
+	var types map[string]bool
+	if list := viper.GetStringSlice("kafka.events.news.types"); len(list) != 0 {
+		types = make(map[string]bool, len(list))
+		for _, t := range list {
+			types[t] = true
+		}
+	}
+
+	return &NewsEventsPublisher{
+		log:    log,
+		outbox: outbox,
+		topic:  topic,
+		types:  types,
+	}
+}
+
+func (p *NewsEventsPublisher) Publish(ctx context.Context, event *domain.NewsEvent) error {
+
+	if p.outbox == nil {
+		return nil
+	}
+	if p.types != nil && !p.types[event.Type] {
+		return nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrapf(err, "cannot marshal %s event of news %d", event.Type, event.NewsId)
+	}
+
+	// Trace of request is kept in outbox, so relay sends it with event
+	headers := kafka.TraceHeaders(ctx)
+	if headers == nil {
+		headers = make(map[string]string, 2)
+	}
+	headers["event_type"] = event.Type
+	headers["event_version"] = strconv.Itoa(event.Version)
+
+	err = p.outbox.Add(ctx, &domain.OutboxMessage{
+		Topic:   p.topic,
+		Key:     strconv.FormatInt(int64(event.NewsId), 10),
+		Payload: payload,
+		Headers: headers,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "cannot publish %s event of news %d", event.Type, event.NewsId)
+	}
+	return nil
+}
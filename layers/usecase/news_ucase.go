@@ -4,22 +4,39 @@ import (
 	"context"
 	"microservice/app/core"
 	"microservice/layers/domain"
+	"time"
 
+	"github.com/avito-tech/go-transaction-manager/trm/manager"
 	"github.com/pkg/errors"
 )
 
 type NewsUseCase struct {
-	log  core.Logger
-	repo domain.NewsRepository
+	log    core.Logger
+	tr     *manager.Manager
+	repo   domain.NewsRepository
+	events domain.NewsEventPublisher
 }
 
-func NewNewsUseCase(log core.Logger, repo domain.NewsRepository) *NewsUseCase {
+func NewNewsUseCase(log core.Logger, tr *manager.Manager, repo domain.NewsRepository, events domain.NewsEventPublisher) *NewsUseCase {
 	return &NewsUseCase{
-		log:  log,
-		repo: repo,
+		log:    log,
+		tr:     tr,
+		repo:   repo,
+		events: events,
 	}
 }
 
+// Событие пишется в outbox, поэтому publish вызывается внутри транзакции вместе с изменениями
+func (ucase *NewsUseCase) publish(ctx context.Context, eventType string, newsId int32, data *domain.NewsEventData) error {
+	return ucase.events.Publish(ctx, &domain.NewsEvent{
+		Type:       eventType,
+		Version:    domain.NewsEventVersion,
+		NewsId:     newsId,
+		OccurredAt: time.Now().UTC(),
+		News:       data,
+	})
+}
+
 func (ucase *NewsUseCase) GetNews(ctx context.Context, page int32) (domain.GetNewsResponse, error) {
 	// Если передали страницу <= 0, не выходим из функции
 	if page <= 0 {
@@ -103,10 +120,37 @@ func (ucase *NewsUseCase) GetNewsDetails(ctx context.Context, page int32, news_i
 
 func (ucase *NewsUseCase) AddNewsCard(ctx context.Context, newsCard domain.NewsCard) (domain.CreateNewsResponse, error) {
 
-	resId, err := ucase.repo.InsertIfNotExistsNewsCard(ctx, &newsCard)
-	// Ошибка запроса к базе
+	var resId int32
+	err := ucase.tr.Do(ctx, func(ctx context.Context) error {
+		var err error
+		resId, err = ucase.repo.InsertIfNotExistsNewsCard(ctx, &newsCard)
+		// Ошибка запроса к базе
+		if err != nil {
+			return errors.Wrap(err, "InsertIfNotExists")
+		}
+		if resId == 0 {
+			return nil
+		}
+
+		// newsCard обновлён значениями сохранённой строки
+		data := &domain.NewsEventData{
+			Title:    newsCard.Title,
+			Image:    newsCard.Image,
+			Type:     newsCard.Type,
+			IsActive: newsCard.IsActive,
+		}
+		if err := ucase.publish(ctx, domain.NewsCreatedEvent, resId, data); err != nil {
+			return err
+		}
+
+		// Новость, созданная сразу активной, тоже выходит в ленту
+		if newsCard.IsActive {
+			return ucase.publish(ctx, domain.NewsPublishedEvent, resId, data)
+		}
+		return nil
+	})
 	if err != nil {
-		return domain.CreateNewsResponse{}, errors.Wrap(err, "InsertIfNotExists")
+		return domain.CreateNewsResponse{}, err
 	}
 
 	if resId == 0 {
@@ -138,9 +182,16 @@ func (ucase *NewsUseCase) AddNewsDetails(ctx context.Context, newsDetails []*dom
 		}, nil
 	}
 
-	err := ucase.repo.InsertIfNotExistsNewsDetails(ctx, newsDetails, news_id)
+	var insertErr error
+	err := ucase.tr.Do(ctx, func(ctx context.Context) error {
+		insertErr = ucase.repo.InsertIfNotExistsNewsDetails(ctx, newsDetails, news_id)
+		if insertErr != nil {
+			return insertErr
+		}
+		return ucase.publish(ctx, domain.NewsUpdatedEvent, news_id, nil)
+	})
 	// Ошибка запроса к базе
-	if err != nil {
+	if insertErr != nil {
 		return domain.CreateNewsDetailesResponse{
 			Status: domain.Status{
 				Code:    domain.ValidationError,
@@ -149,6 +200,9 @@ func (ucase *NewsUseCase) AddNewsDetails(ctx context.Context, newsDetails []*dom
 		}, nil
 
 	}
+	if err != nil {
+		return domain.CreateNewsDetailesResponse{}, errors.Wrap(err, "AddNewsDetails")
+	}
 
 	return domain.CreateNewsDetailesResponse{
 		Status: domain.Status{
@@ -166,7 +220,13 @@ func (ucase *NewsUseCase) DeleteNewsCard(ctx context.Context, id int32) (domain.
 			Message: "id can't have value of <= 0 or id is required",
 		}, nil
 	}
-	err := ucase.repo.DeleteNewsCard(ctx, id)
+	err := ucase.tr.Do(ctx, func(ctx context.Context) error {
+		err := ucase.repo.DeleteNewsCard(ctx, id)
+		if err != nil {
+			return err
+		}
+		return ucase.publish(ctx, domain.NewsDeletedEvent, id, nil)
+	})
 	if err != nil {
 		return domain.Status{
 			Code:    domain.ValidationError,
@@ -188,7 +248,16 @@ func (ucase *NewsUseCase) DeleteNewsDetails(ctx context.Context, id int32) (doma
 			Message: "id can't have value of <= 0 or id is required",
 		}, nil
 	}
-	err := ucase.repo.DeleteNewsDetails(ctx, id)
+	err := ucase.tr.Do(ctx, func(ctx context.Context) error {
+		newsId, err := ucase.repo.DeleteNewsDetails(ctx, id)
+		if err != nil {
+			return err
+		}
+		if newsId == 0 {
+			return nil
+		}
+		return ucase.publish(ctx, domain.NewsUpdatedEvent, newsId, nil)
+	})
 	if err != nil {
 		return domain.Status{
 			Code:    domain.ValidationError,
@@ -201,3 +270,210 @@ func (ucase *NewsUseCase) DeleteNewsDetails(ctx context.Context, id int32) (doma
 		Message: domain.Success,
 	}, nil
 }
+
+// Карточки не найдены, транзакция откатывается
+var errNewsNotFound = errors.New("news not found")
+
+// GetAllNews - лента для админки, вместе с неопубликованными новостями
+func (ucase *NewsUseCase) GetAllNews(ctx context.Context, page int32) (domain.GetNewsResponse, error) {
+	if page <= 0 {
+		return domain.GetNewsResponse{
+			Status: domain.Status{
+				Code:    domain.ValidationError,
+				Message: "page can't have value of <= 0 or page is required",
+			},
+			News: []*domain.NewsCard{},
+		}, nil
+	}
+
+	repoRes, err := ucase.repo.FetchAllByPageNumber(ctx, page)
+	if err != nil {
+		return domain.GetNewsResponse{}, errors.Wrap(err, "FetchAllByPageNumber")
+	}
+	if repoRes == nil {
+		repoRes = []*domain.NewsCard{}
+	}
+
+	return domain.GetNewsResponse{
+		Status: domain.Status{
+			Code:    domain.Success,
+			Message: domain.Success,
+		},
+		News: repoRes,
+	}, nil
+}
+
+func (ucase *NewsUseCase) GetNewsCard(ctx context.Context, id int32) (domain.GetNewsCardResponse, error) {
+	if id <= 0 {
+		return domain.GetNewsCardResponse{
+			Status: domain.Status{
+				Code:    domain.ValidationError,
+				Message: "id can't have value of <= 0 or id is required",
+			},
+		}, nil
+	}
+
+	card, err := ucase.repo.FetchNewsCard(ctx, id)
+	if err != nil {
+		return domain.GetNewsCardResponse{}, errors.Wrap(err, "FetchNewsCard")
+	}
+	if card == nil {
+		return domain.GetNewsCardResponse{
+			Status: domain.Status{
+				Code:    domain.NotFound,
+				Message: "There is no news",
+			},
+		}, nil
+	}
+
+	return domain.GetNewsCardResponse{
+		Status: domain.Status{
+			Code:    domain.Success,
+			Message: domain.Success,
+		},
+		News: card,
+	}, nil
+}
+
+func (ucase *NewsUseCase) UpdateNewsCard(ctx context.Context, newsCard domain.NewsCard) (domain.Status, error) {
+	if newsCard.Id <= 0 || newsCard.Title == "" || newsCard.Image == "" {
+		return domain.Status{
+			Code:    domain.ValidationError,
+			Message: "id, title and image are required",
+		}, nil
+	}
+
+	err := ucase.tr.Do(ctx, func(ctx context.Context) error {
+		found, err := ucase.repo.UpdateNewsCard(ctx, &newsCard)
+		if err != nil {
+			return errors.Wrap(err, "UpdateNewsCard")
+		}
+		if !found {
+			return errNewsNotFound
+		}
+
+		return ucase.publish(ctx, domain.NewsUpdatedEvent, newsCard.Id, &domain.NewsEventData{
+			Title:    newsCard.Title,
+			Image:    newsCard.Image,
+			Type:     newsCard.Type,
+			IsActive: newsCard.IsActive,
+		})
+	})
+	if errors.Is(err, errNewsNotFound) {
+		return domain.Status{
+			Code:    domain.NotFound,
+			Message: "There is no news",
+		}, nil
+	}
+	if err != nil {
+		return domain.Status{}, err
+	}
+
+	return domain.Status{
+		Code:    domain.Success,
+		Message: domain.Success,
+	}, nil
+}
+
+// PublishNewsCard показывает новость в ленте, событие news.published отправляется только при первой публикации
+func (ucase *NewsUseCase) PublishNewsCard(ctx context.Context, id int32) (domain.Status, error) {
+	if id <= 0 {
+		return domain.Status{
+			Code:    domain.ValidationError,
+			Message: "id can't have value of <= 0 or id is required",
+		}, nil
+	}
+
+	var published bool
+	err := ucase.tr.Do(ctx, func(ctx context.Context) error {
+		card, err := ucase.repo.FetchNewsCard(ctx, id)
+		if err != nil {
+			return errors.Wrap(err, "FetchNewsCard")
+		}
+		if card == nil {
+			return errNewsNotFound
+		}
+		if card.IsActive {
+			published = true
+			return nil
+		}
+
+		if err := ucase.repo.PublishNewsCard(ctx, id); err != nil {
+			return errors.Wrap(err, "PublishNewsCard")
+		}
+
+		return ucase.publish(ctx, domain.NewsPublishedEvent, id, &domain.NewsEventData{
+			Title:    card.Title,
+			Image:    card.Image,
+			Type:     card.Type,
+			IsActive: true,
+		})
+	})
+	if errors.Is(err, errNewsNotFound) {
+		return domain.Status{
+			Code:    domain.NotFound,
+			Message: "There is no news",
+		}, nil
+	}
+	if err != nil {
+		return domain.Status{}, err
+	}
+
+	if published {
+		return domain.Status{
+			Code:    domain.AlreadyExists,
+			Message: "news is already published",
+		}, nil
+	}
+
+	return domain.Status{
+		Code:    domain.Success,
+		Message: domain.Success,
+	}, nil
+}
+
+// ReorderNews ставит новости в ленте в порядке ids на занятые ими места, остальные новости остаются на своих местах
+func (ucase *NewsUseCase) ReorderNews(ctx context.Context, ids []int32) (domain.Status, error) {
+	seen := make(map[int32]bool, len(ids))
+	for _, id := range ids {
+		if id <= 0 || seen[id] {
+			return domain.Status{
+				Code:    domain.ValidationError,
+				Message: "ids should be unique and > 0",
+			}, nil
+		}
+		seen[id] = true
+	}
+	if len(ids) == 0 {
+		return domain.Status{
+			Code:    domain.ValidationError,
+			Message: "ids are required",
+		}, nil
+	}
+
+	err := ucase.tr.Do(ctx, func(ctx context.Context) error {
+		count, err := ucase.repo.ReorderNews(ctx, ids)
+		if err != nil {
+			return errors.Wrap(err, "ReorderNews")
+		}
+		// Порядок меняется, только если найдены все ids
+		if count != int64(len(ids)) {
+			return errNewsNotFound
+		}
+		return nil
+	})
+	if errors.Is(err, errNewsNotFound) {
+		return domain.Status{
+			Code:    domain.NotFound,
+			Message: "some of news don't exist",
+		}, nil
+	}
+	if err != nil {
+		return domain.Status{}, err
+	}
+
+	return domain.Status{
+		Code:    domain.Success,
+		Message: domain.Success,
+	}, nil
+}
@@ -0,0 +1,53 @@
+package usecase
+
+import (
+	"context"
+	"microservice/app/core"
+	"microservice/layers/domain"
+
+	"github.com/avito-tech/go-transaction-manager/trm/manager"
+	"github.com/pkg/errors"
+)
+
+type UserUseCase struct {
+	log  core.Logger
+	tr   *manager.Manager
+	repo domain.UserDataRepository
+}
+
+func NewUserUseCase(log core.Logger, tr *manager.Manager, repo domain.UserDataRepository) *UserUseCase {
+	return &UserUseCase{
+		log:  log,
+		tr:   tr,
+		repo: repo,
+	}
+}
+
+// Повторное событие для того же пользователя ничего не меняет
+func (ucase *UserUseCase) EraseUser(ctx context.Context, userId int64) error {
+	if userId <= 0 {
+		return errors.Errorf("incorrect user_id %d", userId)
+	}
+
+	var requestId string
+	if trace, ok := core.TraceFromContext(ctx); ok {
+		requestId = trace.RequestId
+	}
+
+	var erased bool
+	err := ucase.tr.Do(ctx, func(ctx context.Context) error {
+		var err error
+		erased, err = ucase.repo.EraseUser(ctx, userId, requestId)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "cannot erase data of user %d", userId)
+	}
+
+	if erased {
+		ucase.log.FromContext(ctx).Info("AUDIT: data of user %d was erased", userId)
+	} else {
+		ucase.log.FromContext(ctx).Info("AUDIT: data of user %d was already erased", userId)
+	}
+	return nil
+}
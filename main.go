@@ -3,10 +3,16 @@ package main
 import (
 	"log"
 	"microservice/bootstrap"
+	"os"
 )
 
 func main() {
-	err := bootstrap.Run()
+	var err error
+	if len(os.Args) > 1 {
+		err = bootstrap.RunCommand(os.Args[1:])
+	} else {
+		err = bootstrap.Run()
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE
+    IF NOT EXISTS outbox (
+        id BIGSERIAL PRIMARY KEY,
+        topic VARCHAR(255) NOT NULL,
+        message_key VARCHAR(255) NOT NULL,
+        payload BYTEA NOT NULL,
+        headers JSONB NOT NULL DEFAULT '{}',
+
+        attempts INTEGER NOT NULL DEFAULT 0,
+        last_error TEXT DEFAULT NULL,
+        next_attempt_at timestamp(0) NOT NULL DEFAULT now (),
+
+        created_at timestamp(0) NOT NULL DEFAULT now (),
+        delivered_at timestamp(0) DEFAULT NULL
+    );
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (id) WHERE delivered_at IS NULL;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "outbox";
+
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE
+    IF NOT EXISTS kafka_offsets (
+        consumer VARCHAR(255) NOT NULL,
+        topic VARCHAR(255) NOT NULL,
+        partition_id INTEGER NOT NULL,
+        next_offset BIGINT NOT NULL,
+
+        updated_at timestamp(0) NOT NULL DEFAULT now (),
+
+        PRIMARY KEY (consumer, topic, partition_id)
+    );
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "kafka_offsets";
+
+-- +goose StatementEnd
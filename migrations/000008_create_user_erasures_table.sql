@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE
+    IF NOT EXISTS user_erasures (
+        user_id BIGINT PRIMARY KEY,
+        request_id VARCHAR(255) NOT NULL DEFAULT '',
+        erased_at timestamp(0) NOT NULL DEFAULT now ()
+    );
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "user_erasures";
+
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE news ADD COLUMN IF NOT EXISTS position INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS news_position_idx ON news (position, id) WHERE deleted_at IS NULL;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS news_position_idx;
+ALTER TABLE news DROP COLUMN IF EXISTS position;
+
+-- +goose StatementEnd
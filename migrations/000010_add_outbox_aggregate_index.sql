@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS outbox_pending_aggregate_idx ON outbox (topic, message_key, id) WHERE delivered_at IS NULL;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS outbox_pending_aggregate_idx;
+
+-- +goose StatementEnd